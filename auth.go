@@ -0,0 +1,385 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sessionCookieName = "devco_session"
+
+const defaultSessionMaxAge = 7 * 24 * time.Hour
+
+// way to log in to devco.
+// sessions are shared by all providers, so a provider only has to verify the user.
+type authProvider interface {
+	// verify credentials carried by the request itself (like Authorization header).
+	// returns the user name.
+	authenticateRequest(r *http.Request) (string, bool)
+	// handle `/login`. call [sessionManager.login] after the user is verified.
+	serveLogin(w http.ResponseWriter, r *http.Request, sessions *sessionManager)
+}
+
+// signed session cookies.
+// the signing key is kept in datadir so sessions survive restarts.
+type sessionManager struct {
+	key    []byte
+	maxAge time.Duration
+	// base domain of port hosts. the cookie is shared with its subdomains
+	// when devco is accessed on the domain.
+	domain string
+}
+
+func newSessionManager(datadir string, maxAge time.Duration, domain string) (*sessionManager, error) {
+	key, err := loadOrCreateKey(path.Join(datadir, "session.key"))
+	if err != nil {
+		return nil, err
+	}
+	return &sessionManager{key: key, maxAge: maxAge, domain: domain}, nil
+}
+
+// Domain attribute of the cookie for r
+func (s *sessionManager) cookieDomain(r *http.Request) string {
+	if s.domain != "" && hostMatches(r.Host, s.domain) {
+		return s.domain
+	}
+	return ""
+}
+
+// read 32 bytes random key from keypath. creates it if not exists.
+func loadOrCreateKey(keypath string) ([]byte, error) {
+	key, err := os.ReadFile(keypath)
+	if err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error read key `%s`: %s", keypath, err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("error generate key: %s", err)
+	}
+	if err := os.WriteFile(keypath, key, 0o600); err != nil {
+		return nil, fmt.Errorf("error write key `%s`: %s", keypath, err)
+	}
+	return key, nil
+}
+
+func (s *sessionManager) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// set session cookie for user
+func (s *sessionManager) login(w http.ResponseWriter, r *http.Request, user string) {
+	expires := time.Now().Add(s.maxAge)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + strconv.FormatInt(expires.Unix(), 10)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    payload + "." + s.sign(payload),
+		Path:     "/",
+		Domain:   s.cookieDomain(r),
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *sessionManager) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		Domain:   s.cookieDomain(r),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// user of the session cookie
+func (s *sessionManager) user(r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return "", false
+	}
+
+	i := strings.LastIndex(c.Value, ".")
+	if i == -1 {
+		return "", false
+	}
+	payload, sig := c.Value[:i], c.Value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return "", false
+	}
+
+	userb64, expstr, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	exp, err := strconv.ParseInt(expstr, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return "", false
+	}
+	user, err := base64.RawURLEncoding.DecodeString(userb64)
+	if err != nil {
+		return "", false
+	}
+
+	return string(user), true
+}
+
+type authManager struct {
+	provider authProvider
+	sessions *sessionManager
+}
+
+func (a *authManager) authenticate(r *http.Request) (string, bool) {
+	if user, ok := a.sessions.user(r); ok {
+		return user, true
+	}
+	return a.provider.authenticateRequest(r)
+}
+
+// do not pass devco's token in the Authorization header to the apps in containers.
+// headers the provider does not accept are left as they are, they may be for the app.
+func (a *authManager) stripCredentials(r *http.Request) {
+	if a == nil {
+		return
+	}
+	if _, ok := a.provider.authenticateRequest(r); ok {
+		r.Header.Del("Authorization")
+	}
+}
+
+// paths that need authentication
+func requiresAuth(p string) bool {
+	return strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/port/")
+}
+
+// protect /api/* and /port/* with auth. auth == nil means no authentication.
+// /port/* of shared ports can be accessed with share links too.
+func authMiddleware(auth *authManager, shares *portShares, next http.Handler) http.Handler {
+	if auth == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requiresAuth(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if _, ok := auth.authenticate(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if pid, wid, port, ok := parsePortPath(r.URL.Path); ok {
+			if shares.allow(w, r, pid, wid, port, fmt.Sprintf("/port/%s/%s/%d/", pid, wid, port)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		// send browsers to the login page
+		if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/port/") && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="devco"`)
+		errPrint(w, http.StatusUnauthorized, "error authentication required")
+	})
+}
+
+// reject cross-site state-changing requests to /api/*.
+// the session cookie is shared with the apps on subdomains of PortDomain (same site),
+// so SameSite does not stop them. browsers send Origin with such requests,
+// and a form or a simple request cannot send a JSON body without a CORS preflight.
+// DELETE without body cannot be sent cross-origin without a preflight either.
+func csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !strings.EqualFold(u.Host, r.Host) {
+				errPrint(w, http.StatusForbidden, "error cross-origin request from `%s` is not allowed", origin)
+				return
+			}
+		}
+
+		if r.Method == http.MethodDelete && r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+			errPrint(w, http.StatusUnsupportedMediaType, "error Content-Type must be application/json")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func serveAuthAPI(auth *authManager) {
+	if auth == nil {
+		return
+	}
+
+	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		auth.provider.serveLogin(w, r, auth.sessions)
+	})
+
+	http.HandleFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) {
+		auth.sessions.logout(w, r)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	})
+}
+
+func newAuthManager(datadir string, c authConfig, portDomain string) (*authManager, error) {
+	var provider authProvider
+	switch c.Type {
+	case "":
+		if c.Token == "" {
+			return nil, nil
+		}
+		provider = &tokenAuth{token: c.Token}
+	case "token":
+		if c.Token == "" {
+			return nil, errors.New("Auth.Token is empty")
+		}
+		provider = &tokenAuth{token: c.Token}
+	default:
+		return nil, fmt.Errorf("unknown Auth.Type `%s`", c.Type)
+	}
+
+	maxAge := defaultSessionMaxAge
+	if c.SessionMaxAge != "" {
+		d, err := time.ParseDuration(c.SessionMaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Auth.SessionMaxAge `%s`: %s", c.SessionMaxAge, err)
+		}
+		maxAge = d
+	}
+
+	sessions, err := newSessionManager(datadir, maxAge, portDomain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authManager{provider: provider, sessions: sessions}, nil
+}
+
+// one shared secret, used as bearer token and as password of the login page
+type tokenAuth struct {
+	token string
+}
+
+const tokenAuthUser = "devco"
+
+func (t *tokenAuth) check(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(t.token)) == 1
+}
+
+func (t *tokenAuth) authenticateRequest(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !t.check(token) {
+		return "", false
+	}
+	return tokenAuthUser, true
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>devco login</title>
+<style>
+body { font-family: sans-serif; display: flex; justify-content: center; margin-top: 15vh; background: #f8fafc; }
+form { display: flex; flex-direction: column; gap: 0.75rem; width: 18rem; }
+input, button { padding: 0.5rem; font-size: 1rem; }
+.error { color: #b91c1c; }
+</style>
+</head>
+<body>
+<form method="post" action="/login">
+<h1>devco</h1>
+{{if .Failed}}<p class="error">invalid token</p>{{end}}
+<input type="password" name="token" placeholder="token" autofocus required>
+<input type="hidden" name="next" value="{{.Next}}">
+<button type="submit">login</button>
+</form>
+</body>
+</html>
+`))
+
+func (t *tokenAuth) serveLogin(w http.ResponseWriter, r *http.Request, sessions *sessionManager) {
+	type page struct {
+		Failed bool
+		Next   string
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		loginPage.Execute(w, page{Next: r.URL.Query().Get("next")})
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			errPrint(w, http.StatusBadRequest, "error parse form: %s", err)
+			return
+		}
+
+		next := r.PostForm.Get("next")
+		if !t.check(r.PostForm.Get("token")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			loginPage.Execute(w, page{Failed: true, Next: next})
+			return
+		}
+
+		sessions.login(w, r, tokenAuthUser)
+		http.Redirect(w, r, safeRedirect(next, sessions.domain), http.StatusSeeOther)
+	default:
+		errPrint(w, http.StatusMethodNotAllowed, "error method `%s` not allowed", r.Method)
+	}
+}
+
+// only allow redirect to a path on this server, or to a port host under domain
+func safeRedirect(next string, domain string) string {
+	if domain != "" {
+		if u, err := url.Parse(next); err == nil && (u.Scheme == "http" || u.Scheme == "https") && hostMatches(u.Host, domain) {
+			return next
+		}
+	}
+
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check the environment devco needs (git, docker, devcontainer CLI, datadir, projects)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var checks []checkResult
+
+		if err := setupRuntime(conf.Runtime); err != nil {
+			checks = append(checks, checkResult{Name: "config", Status: checkError, Detail: err.Error(), Fix: "fix Runtime in the config file"})
+		}
+
+		var st projectStore
+		if exist(data_dir) {
+			// do not migrate or import the store while a devco server is using it
+			lock, inUse, err := lockDatadirShared(data_dir)
+			switch {
+			case err != nil:
+			case inUse:
+				st, err = openProjectStoreReadOnly(data_dir, conf.Store)
+			default:
+				defer lock.Close()
+				st, err = openProjectStore(data_dir, conf.Store)
+			}
+			if err != nil {
+				checks = append(checks, checkResult{Name: "store", Status: checkError, Detail: err.Error(), Fix: "check the store in datadir (projects.json or devco.db)"})
+			} else {
+				defer st.Close()
+			}
+		}
+
+		checks = append(checks, checkEnvironment(data_dir, st)...)
+
+		failed := false
+		for _, c := range checks {
+			fmt.Printf("%-9s %-24s %s\n", "["+string(c.Status)+"]", c.Name, c.Detail)
+			if c.Fix != "" {
+				fmt.Printf("%-9s %-24s fix: %s\n", "", "", c.Fix)
+			}
+			if c.Status == checkError {
+				failed = true
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
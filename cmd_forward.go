@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/coder/websocket"
+	"github.com/spf13/cobra"
+)
+
+var forwardCmd = &cobra.Command{
+	Use:   "forward <project> <workspace> <remotePort> [localPort]",
+	Short: "forward local TCP port to a port of the workspace",
+	Args:  cobra.RangeArgs(3, 4),
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, err := strconv.ParseUint(args[2], 10, 16)
+		if err != nil || remote == 0 {
+			log.Fatalf("invalid remotePort `%s`", args[2])
+		}
+		local := remote
+		if len(args) == 4 {
+			local, err = strconv.ParseUint(args[3], 10, 16)
+			if err != nil {
+				log.Fatalf("invalid localPort `%s`", args[3])
+			}
+		}
+
+		token := forward_token
+		if token == "" {
+			token = os.Getenv("DEVCO_TOKEN")
+		}
+		if token == "" {
+			token = conf.Auth.Token
+		}
+
+		err = forward(forward_server, token, forward_insecure, args[0], args[1], int(remote), net.JoinHostPort(forward_bind, strconv.Itoa(int(local))))
+		if err != nil {
+			log.Fatalf("forward error: %s", err)
+		}
+	},
+}
+
+var forward_server string
+var forward_token string
+var forward_bind string
+var forward_insecure bool
+
+func init() {
+	forwardCmd.Flags().StringVarP(&forward_server, "server", "s", "http://localhost:8000", "URL of devco server")
+	forwardCmd.Flags().StringVar(&forward_token, "token", "", "auth token (default: $DEVCO_TOKEN or Auth.Token in config)")
+	forwardCmd.Flags().StringVar(&forward_bind, "bind", "127.0.0.1", "local address to listen")
+	forwardCmd.Flags().BoolVar(&forward_insecure, "insecure", false, "skip TLS certificate verification")
+
+	rootCmd.AddCommand(forwardCmd)
+}
+
+// listen on laddr and pipe each connection to the tunnel API
+func forward(server string, token string, insecure bool, pjname string, wsname string, port int, laddr string) error {
+	u, err := url.Parse(strings.TrimRight(server, "/") + "/api/workspace/tunnel")
+	if err != nil {
+		return fmt.Errorf("invalid server URL `%s`: %s", server, err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return fmt.Errorf("invalid server URL `%s`: scheme must be http or https", server)
+	}
+	q := u.Query()
+	q.Set("pjname", pjname)
+	q.Set("wsname", wsname)
+	q.Set("port", strconv.Itoa(port))
+	u.RawQuery = q.Encode()
+
+	opts := &websocket.DialOptions{HTTPHeader: http.Header{}}
+	if token != "" {
+		opts.HTTPHeader.Set("Authorization", "Bearer "+token)
+	}
+	if insecure {
+		opts.HTTPClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	}
+
+	ln, err := net.Listen("tcp", laddr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	log.Printf("forwarding %s -> port %d of workspace `%s` in project `%s`", ln.Addr(), port, wsname, pjname)
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		go func() {
+			defer c.Close()
+
+			conn, res, err := websocket.Dial(ctx, u.String(), opts)
+			if err != nil {
+				if res != nil {
+					b, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+					log.Printf("error open tunnel: %s: %s", res.Status, strings.TrimSpace(string(b)))
+				} else {
+					log.Printf("error open tunnel: %s", err)
+				}
+				return
+			}
+			defer conn.CloseNow()
+
+			if err := pipe(ctx, conn, c); err != nil {
+				log.Printf("tunnel closed: %s", err)
+			}
+			conn.Close(websocket.StatusNormalClosure, "")
+		}()
+	}
+}
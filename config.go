@@ -1,15 +1,245 @@
 package main
 
+import (
+	"fmt"
+	"time"
+)
+
 type config struct {
 	Plugins map[string]plugin
+	// backend that saves projects and workspaces: "json" (default) or "sqlite"
+	Store string
+	// interval of checking workspace states with docker (like "30s").
+	// "0" disables periodic check. default: 1m
+	ReconcileInterval string
+	// what to do with running containers when devco exits:
+	// "down" (default), "stop" or "leave"
+	ShutdownPolicy string
+	// authentication of /api/* and /port/*. disabled when empty
+	Auth authConfig
+	// serve HTTPS. disabled when empty
+	TLS tlsConfig
+	// base domain of port hosts (like "devco.example.com").
+	// `{port}-{wsname}-{pjname}.<PortDomain>` is proxied to the port of the workspace.
+	// point a wildcard DNS record (*.<PortDomain>) to devco, and access devco itself
+	// on <PortDomain> to share the login session with port hosts.
+	PortDomain string
+	// interval of detecting listening ports in running workspaces (like "10s").
+	// "0" disables periodic detection. default: 10s
+	PortScanInterval string
+	// proxy any port of running workspaces.
+	// by default only ports that are declared, detected or have visibility set are proxied
+	ProxyAllPorts bool
+	// SSH gateway to workspaces. disabled when empty
+	SSH sshConfig
+	// container runtime. docker by default
+	Runtime runtimeConfig
+	// time limits of container operations. stuck operations are killed when exceeded
+	Timeouts timeoutsConfig
+}
+
+type authConfig struct {
+	// "token" (default when Token is set)
+	Type string
+	// bearer token. also the password of the login page
+	Token string
+	// lifetime of login sessions (like "24h"). default: 168h
+	SessionMaxAge string
+}
+
+type tlsConfig struct {
+	// certificate and key files (PEM)
+	Cert string
+	Key  string
+	// generate a local CA and a server certificate in datadir (used when Cert is empty)
+	Auto bool
+	// hostnames and IPs covered by the generated certificate.
+	// localhost and the hostname of this machine are always covered.
+	Hosts []string
+}
+
+type runtimeConfig struct {
+	// "docker" (default) or "podman". podman changes the defaults below
+	Type string
+	// CLI of the runtime. default: "docker" ("podman")
+	DockerPath string
+	// compose CLI (like "podman-compose"). default: compose subcommand of DockerPath
+	DockerComposePath string
+	// address of the Engine API (like "unix:///run/user/1000/podman/podman.sock").
+	// default: DOCKER_HOST or /var/run/docker.sock
+	// ($XDG_RUNTIME_DIR/podman/podman.sock for rootless podman)
+	Socket string
+	// how devco connects to ports of containers: "ip" (IP of the container)
+	// or "published" (ports published to the host by `appPort` or `runArgs`).
+	// default: "published" for rootless podman, where container IPs are not reachable, otherwise "ip"
+	Network string
+}
+
+// durations like "30m". "0" disables the limit
+type timeoutsConfig struct {
+	// launch and rebuild, including image pulls and builds.
+	// the workspace is cleaned up when exceeded. default: 30m
+	Launch string
+	// default: 2m
+	Down string
+	// default: 2m
+	Stop string
+	// default: 2m
+	Start string
+	// PostStartCommands of plugins after launch and start, and lifecycle commands
+	// of devcontainer.json after start. the container keeps running when exceeded. default: 10m
+	PostStart string
+}
+
+type sshConfig struct {
+	// address of SSH listener (like ":2222").
+	// log in as `{project}.{workspace}` with a key registered by /api/ssh/keys.
+	// local port forwards (`ssh -L`) are supported, remote ones (`ssh -R`) are not
+	Address string
 }
 
+type shutdownPolicy string
+
+const (
+	// remove containers
+	shutdownDown shutdownPolicy = "down"
+	// stop containers and keep them
+	shutdownStop shutdownPolicy = "stop"
+	// keep containers running
+	shutdownLeave shutdownPolicy = "leave"
+)
+
 type plugin struct {
 	Features map[string]map[string]any // bool or string
 	Links    map[string]link
+	Mounts   []mount
+	// shell commands run in the container after launch, rebuild and start, in order.
+	// the launch waits for each command, so start servers in background with output redirected
+	// (like `nohup code-server >/tmp/code-server.log 2>&1 &`)
+	PostStartCommands []string
+}
+
+// mount added to the container.
+// Source and Target can contain `${projectName}`, `${workspaceName}` and `${userHome}`, and Source can start with `~`.
+type mount struct {
+	// "bind" (default) or "volume"
+	Type string
+	// host path (bind) or volume name (volume)
+	Source string
+	// absolute path in the container
+	Target string
+	// use the volume created outside of devcontainer (volume only)
+	External bool `json:",omitempty"`
 }
 
 type link struct {
 	Port int // 0 means "not need open button"
 	Path string
+	// protocol of the port: "http" (default) or "https"
+	Protocol string `json:",omitempty"`
+	// check the port is ready before the link is shown. always shown when nil
+	Probe *probe `json:",omitempty"`
+}
+
+type probe struct {
+	// "http" (default): GET Path and any response other than 5xx. "tcp": connect to the port
+	Type string
+	// path of HTTP probe. default: Path of the link
+	Path string `json:",omitempty"`
+}
+
+func (c config) reconcileInterval() (time.Duration, error) {
+	if c.ReconcileInterval == "" {
+		return defaultReconcileInterval, nil
+	}
+
+	d, err := time.ParseDuration(c.ReconcileInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ReconcileInterval `%s`: %s", c.ReconcileInterval, err)
+	}
+	return d, nil
+}
+
+func (c config) shutdownPolicy() (shutdownPolicy, error) {
+	switch p := shutdownPolicy(c.ShutdownPolicy); p {
+	case "":
+		return shutdownDown, nil
+	case shutdownDown, shutdownStop, shutdownLeave:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid ShutdownPolicy `%s` (down, stop or leave)", c.ShutdownPolicy)
+	}
+}
+
+func (c config) portScanInterval() (time.Duration, error) {
+	if c.PortScanInterval == "" {
+		return defaultPortScanInterval, nil
+	}
+
+	d, err := time.ParseDuration(c.PortScanInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PortScanInterval `%s`: %s", c.PortScanInterval, err)
+	}
+	return d, nil
+}
+
+// parsed timeoutsConfig. 0 means no limit
+type operationTimeouts struct {
+	Launch    time.Duration
+	Down      time.Duration
+	Stop      time.Duration
+	Start     time.Duration
+	PostStart time.Duration
+}
+
+func (c config) timeouts() (operationTimeouts, error) {
+	parse := func(name string, s string, def time.Duration) (time.Duration, error) {
+		if s == "" {
+			return def, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid Timeouts.%s `%s`: %s", name, s, err)
+		}
+		return d, nil
+	}
+
+	var r operationTimeouts
+	var err error
+	if r.Launch, err = parse("Launch", c.Timeouts.Launch, defaultLaunchTimeout); err != nil {
+		return operationTimeouts{}, err
+	}
+	if r.Down, err = parse("Down", c.Timeouts.Down, defaultOperationTimeout); err != nil {
+		return operationTimeouts{}, err
+	}
+	if r.Stop, err = parse("Stop", c.Timeouts.Stop, defaultOperationTimeout); err != nil {
+		return operationTimeouts{}, err
+	}
+	if r.Start, err = parse("Start", c.Timeouts.Start, defaultOperationTimeout); err != nil {
+		return operationTimeouts{}, err
+	}
+	if r.PostStart, err = parse("PostStart", c.Timeouts.PostStart, defaultPostStartTimeout); err != nil {
+		return operationTimeouts{}, err
+	}
+	return r, nil
+}
+
+// check mounts and links of all plugins
+func (c config) validatePlugins() error {
+	for name, p := range c.Plugins {
+		for _, m := range p.Mounts {
+			if err := m.validate(); err != nil {
+				return fmt.Errorf("plugin `%s`: %s", name, err)
+			}
+		}
+		for lname, l := range p.Links {
+			if l.Probe == nil {
+				continue
+			}
+			if err := l.Probe.validate(); err != nil {
+				return fmt.Errorf("plugin `%s`: link `%s`: %s", name, lname, err)
+			}
+		}
+	}
+	return nil
 }
@@ -1,5 +1,13 @@
 package devcontainer
 
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
 type MountConfig struct {
 	// [MountType]. See Docker Document
 	Type MountType
@@ -18,3 +26,45 @@ const (
 	BindMount MountType = iota
 	VolumeMount
 )
+
+// environment of CLI commands. DOCKER_HOST is set when dockerHost is not empty
+func cliEnv(dockerHost string) []string {
+	if dockerHost == "" {
+		return nil
+	}
+	return append(os.Environ(), "DOCKER_HOST="+dockerHost)
+}
+
+// how long Wait waits for the output pipes after the command is killed.
+// grandchildren may keep them open.
+const waitDelay = 5 * time.Second
+
+// command killed with its whole process group when ctx is done
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// error of a command killed by ctx wraps ctx.Err() (context.Canceled or context.DeadlineExceeded)
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%w: %s", ctx.Err(), err)
+	}
+	return err
+}
+
+// `docker compose args...`, or `{composePath} args...` if composePath is set
+func composeCommand(ctx context.Context, dockerPath string, composePath string, args ...string) *exec.Cmd {
+	if composePath != "" {
+		return commandContext(ctx, composePath, args...)
+	}
+	if dockerPath == "" {
+		dockerPath = "docker"
+	}
+	return commandContext(ctx, dockerPath, append([]string{"compose"}, args...)...)
+}
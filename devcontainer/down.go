@@ -1,39 +1,114 @@
 package devcontainer
 
 import (
+	"context"
 	"errors"
-	"os/exec"
+	"fmt"
+
+	"github.com/0x5341/devco/docker"
 )
 
+// config of [Down].
+// Down talks to the Docker Engine API, so paths of the docker and compose CLIs are not needed.
 type DownConfig struct {
-	DockerPath string
+	// address of the docker daemon (like "unix:///var/run/docker.sock").
+	// default: DOCKER_HOST or [docker.DefaultHost]
+	DockerHost string
 
 	ContainerId        string
 	ComposeProjectName string
+	// workspace path. used when ContainerId and ComposeProjectName are unknown
+	// (like [UpContext] is canceled) to find containers created by the CLI for the workspace
+	WorkspaceFolder string
 }
 
+// remove the container, or the containers and networks of the compose project.
+// errors from the daemon are [*docker.APIError] (errors.Is works with [docker.ErrNotFound]).
 func Down(c DownConfig) error {
-	if c.ComposeProjectName != "" {
-		var dpath string
-		if c.DockerPath != "" {
-			dpath = c.DockerPath
-		} else {
-			dpath = "docker"
-		}
-		err := exec.Command(dpath, "compose", "-p", c.ComposeProjectName, "down").Run()
+	return DownContext(context.Background(), c)
+}
+
+// same as [Down]. requests to the daemon are canceled when ctx is done
+func DownContext(ctx context.Context, c DownConfig) error {
+	cli, err := docker.NewClient(c.DockerHost)
+	if err != nil {
 		return err
 	}
 
+	if c.ComposeProjectName != "" {
+		return downCompose(ctx, cli, c.ComposeProjectName)
+	}
+
 	if c.ContainerId != "" {
-		var dpath string
-		if c.DockerPath != "" {
-			dpath = c.DockerPath
-		} else {
-			dpath = "docker"
-		}
-		err := exec.Command(dpath, "rm", "-f", c.ContainerId).Run()
-		return err
+		return cli.ContainerRemove(ctx, c.ContainerId, docker.ContainerRemoveOptions{Force: true})
+	}
+
+	if c.WorkspaceFolder != "" {
+		return downWorkspace(ctx, cli, c.WorkspaceFolder)
 	}
 
 	return errors.New("cannnot find any compose project or container")
 }
+
+// same as `docker compose -p project down`
+func downCompose(ctx context.Context, cli *docker.Client, project string) error {
+	filters := map[string][]string{"label": {"com.docker.compose.project=" + project}}
+
+	containers, err := cli.ContainerList(ctx, docker.ContainerListOptions{All: true, Filters: filters})
+	if err != nil {
+		return fmt.Errorf("error list containers of compose project `%s`: %w", project, err)
+	}
+
+	var errs []error
+	for _, ct := range containers {
+		err := cli.ContainerRemove(ctx, ct.Id, docker.ContainerRemoveOptions{Force: true})
+		if err != nil && !errors.Is(err, docker.ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		// networks are still used by the remaining containers
+		return errors.Join(errs...)
+	}
+
+	networks, err := cli.NetworkList(ctx, docker.NetworkListOptions{Filters: filters})
+	if err != nil {
+		return fmt.Errorf("error list networks of compose project `%s`: %w", project, err)
+	}
+	for _, n := range networks {
+		err := cli.NetworkRemove(ctx, n.Id)
+		if err != nil && !errors.Is(err, docker.ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// remove containers labeled by the devcontainer CLI with the workspace folder.
+// compose projects of them are removed as a whole.
+func downWorkspace(ctx context.Context, cli *docker.Client, folder string) error {
+	filters := map[string][]string{"label": {"devcontainer.local_folder=" + folder}}
+	containers, err := cli.ContainerList(ctx, docker.ContainerListOptions{All: true, Filters: filters})
+	if err != nil {
+		return fmt.Errorf("error list containers of workspace `%s`: %w", folder, err)
+	}
+
+	var errs []error
+	projects := make(map[string]bool)
+	for _, ct := range containers {
+		if p := ct.Labels["com.docker.compose.project"]; p != "" {
+			projects[p] = true
+			continue
+		}
+		err := cli.ContainerRemove(ctx, ct.Id, docker.ContainerRemoveOptions{Force: true})
+		if err != nil && !errors.Is(err, docker.ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	for p := range projects {
+		if err := downCompose(ctx, cli, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -1,10 +1,43 @@
 package devcontainer
 
 import (
+	"context"
 	"os/exec"
 )
 
+// config of [ExecWith]
+type ExecConfig struct {
+	// docker path (if needed)
+	DockerPath string
+	// address of the docker daemon for the CLI (DOCKER_HOST, if needed)
+	DockerHost string
+	// workspace path
+	WorkspaceFolder string
+}
+
 func Exec(workspace string, cmd string, args ...string) *exec.Cmd {
-	c := exec.Command(devcontainerCliPath, append([]string{"exec", "--workspace-folder", workspace, cmd}, args...)...)
-	return c
+	return ExecWith(ExecConfig{WorkspaceFolder: workspace}, cmd, args...)
+}
+
+// command running cmd in the devcontainer of the workspace
+func ExecWith(c ExecConfig, cmd string, args ...string) *exec.Cmd {
+	var r []string
+	r = append(r, "exec")
+	if c.DockerPath != "" {
+		r = append(r, "--docker-path", c.DockerPath)
+	}
+	r = append(r, "--workspace-folder", c.WorkspaceFolder, cmd)
+
+	e := exec.Command(devcontainerCliPath, append(r, args...)...)
+	e.Env = cliEnv(c.DockerHost)
+	return e
+}
+
+// same as [ExecWith]. the command is killed with its process group when ctx is done.
+// it runs in a new process group, so it can not be started with a controlling terminal (like pty.Start).
+func ExecContext(ctx context.Context, c ExecConfig, cmd string, args ...string) *exec.Cmd {
+	e := ExecWith(c, cmd, args...)
+	r := commandContext(ctx, e.Path, e.Args[1:]...)
+	r.Env = e.Env
+	return r
 }
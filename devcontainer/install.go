@@ -1,21 +1,43 @@
 package devcontainer
 
 import (
+	"fmt"
 	"os/exec"
+	"strings"
 )
 
 var devcontainerCliPath string = "devcontainer"
 
+// find devcontainer CLI in PATH and use it. returns error if it is not installed.
+// call it once at startup, before any other function of this package.
 func DetectDevcontainer() error {
-	path, err := exec.LookPath("devcontainer")
+	path, err := LookupDevcontainer()
 	if err != nil {
-		return nil
+		return err
 	}
 
 	devcontainerCliPath = path
 	return nil
 }
 
+// path of devcontainer CLI in PATH. unlike [DetectDevcontainer] it does not change the CLI in use.
+func LookupDevcontainer() (string, error) {
+	path, err := exec.LookPath("devcontainer")
+	if err != nil {
+		return "", fmt.Errorf("devcontainer CLI is not found: %w", err)
+	}
+	return path, nil
+}
+
 func SetDevcontainerPath(path string) {
 	devcontainerCliPath = path
 }
+
+// version of the devcontainer CLI at path (like "0.72.0")
+func CliVersion(path string) (string, error) {
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("error run `%s --version`: %w", path, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package devcontainer
+
+import (
+	"os/exec"
+)
+
+// process groups are not available. only the command itself is killed.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package devcontainer
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// run cmd in its own process group, so processes started by it (like `docker build`) can be killed together
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,161 @@
+package devcontainer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// config of [ReadConfiguration]
+type ReadConfigurationConfig struct {
+	// docker path (if needed)
+	DockerPath string
+	// docker compose path (if needed)
+	DockerComposePath string
+	// address of the docker daemon for the CLI (DOCKER_HOST, if needed)
+	DockerHost string
+	// workspace path.
+	// default: current directory
+	WorkspaceFolder string
+	// config path (like .devcontainer/devcontainer.json)
+	// default: devcontainer.json found in workspace
+	ConfigPath string
+}
+
+// part of resolved devcontainer.json
+type Configuration struct {
+	ForwardPorts []ForwardPort
+	// key is port ("3000"), port range ("3000-3010") or regular expression.
+	// regular expressions match command lines of processes, and are not used by [Configuration.AttributesOf]
+	PortsAttributes map[string]PortAttributes
+}
+
+// element of `forwardPorts`. `3000` or `"db:5432"`
+type ForwardPort struct {
+	// empty for the port of the devcontainer itself
+	Host string
+	Port int
+}
+
+func (p *ForwardPort) UnmarshalJSON(b []byte) error {
+	var n int
+	if err := json.Unmarshal(b, &n); err == nil {
+		p.Host = ""
+		p.Port = n
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("invalid forwardPorts element `%s`", b)
+	}
+	host, port, ok := strings.Cut(s, ":")
+	if !ok {
+		host, port = "", s
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid forwardPorts element `%s`", s)
+	}
+	p.Host = host
+	p.Port = n
+	return nil
+}
+
+type PortAttributes struct {
+	Label    string `json:"label"`
+	Protocol string `json:"protocol"`
+	// "notify", "openBrowser", "openBrowserOnce", "openPreview", "silent" or "ignore"
+	OnAutoForward string `json:"onAutoForward"`
+}
+
+// attributes of port (exact port or port range key).
+// overlapping ranges are tried in order of the keys, since the order in devcontainer.json is lost
+func (c Configuration) AttributesOf(port int) (PortAttributes, bool) {
+	if a, ok := c.PortsAttributes[strconv.Itoa(port)]; ok {
+		return a, true
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.PortsAttributes)) {
+		a := c.PortsAttributes[k]
+		from, to, ok := strings.Cut(k, "-")
+		if !ok {
+			continue
+		}
+		f, ferr := strconv.Atoi(strings.TrimSpace(from))
+		t, terr := strconv.Atoi(strings.TrimSpace(to))
+		if ferr == nil && terr == nil && f <= port && port <= t {
+			return a, true
+		}
+	}
+	return PortAttributes{}, false
+}
+
+// read devcontainer.json of the workspace, resolved with features and variables
+func ReadConfiguration(c ReadConfigurationConfig) (Configuration, error) {
+	return ReadConfigurationContext(context.Background(), c)
+}
+
+// same as [ReadConfiguration]. the CLI is killed when ctx is done
+func ReadConfigurationContext(ctx context.Context, c ReadConfigurationConfig) (Configuration, error) {
+	cmd := commandContext(ctx, devcontainerCliPath, buildReadConfigurationOption(c)...)
+	cmd.Env = cliEnv(c.DockerHost)
+	out, err := cmd.Output()
+	if err != nil {
+		return Configuration{}, contextError(ctx, err)
+	}
+
+	js := string(out)
+	jindex := strings.Index(js, "{")
+	if jindex == -1 {
+		return Configuration{}, errors.New("not found result json")
+	}
+
+	type configuration struct {
+		ForwardPorts    []ForwardPort             `json:"forwardPorts"`
+		PortsAttributes map[string]PortAttributes `json:"portsAttributes"`
+	}
+	var r struct {
+		Configuration       configuration  `json:"configuration"`
+		MergedConfiguration *configuration `json:"mergedConfiguration"`
+	}
+	if err := json.Unmarshal([]byte(js[jindex:]), &r); err != nil {
+		return Configuration{}, err
+	}
+
+	// merged configuration includes ports declared by features
+	conf := r.Configuration
+	if r.MergedConfiguration != nil {
+		conf = *r.MergedConfiguration
+	}
+	return Configuration{
+		ForwardPorts:    conf.ForwardPorts,
+		PortsAttributes: conf.PortsAttributes,
+	}, nil
+}
+
+func buildReadConfigurationOption(c ReadConfigurationConfig) (r []string) {
+	r = append(r, "read-configuration", "--include-merged-configuration")
+
+	if c.DockerPath != "" {
+		r = append(r, "--docker-path", c.DockerPath)
+	}
+
+	if c.DockerComposePath != "" {
+		r = append(r, "--docker-compose-path", c.DockerComposePath)
+	}
+
+	if c.WorkspaceFolder != "" {
+		r = append(r, "--workspace-folder", c.WorkspaceFolder)
+	}
+
+	if c.ConfigPath != "" {
+		r = append(r, "--config", c.ConfigPath)
+	}
+
+	return
+}
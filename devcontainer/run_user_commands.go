@@ -0,0 +1,94 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// config of [RunUserCommands]
+type RunUserCommandsConfig struct {
+	// docker path (if needed)
+	DockerPath string
+	// docker compose path (if needed)
+	DockerComposePath string
+	// address of the docker daemon for the CLI (DOCKER_HOST, if needed)
+	DockerHost string
+	// workspace path.
+	// default: current directory
+	WorkspaceFolder string
+	// container to run the commands in.
+	// default: the container of the workspace
+	ContainerId string
+	// called with each line of stdout/stderr while the command is running (if needed).
+	// it may be called from multiple goroutines at the same time.
+	OnLog func(stream LogStream, line string)
+}
+
+// run lifecycle commands of devcontainer.json (and its features) in the running container.
+// commands which run once per container (like postCreateCommand) are skipped when they already ran,
+// so after [Start] this runs postStartCommand and postAttachCommand.
+func RunUserCommands(c RunUserCommandsConfig) error {
+	return RunUserCommandsContext(context.Background(), c)
+}
+
+// same as [RunUserCommands]. the CLI and the commands are killed when ctx is done
+func RunUserCommandsContext(ctx context.Context, c RunUserCommandsConfig) error {
+	cmd := commandContext(ctx, devcontainerCliPath, buildRunUserCommandsOption(c)...)
+	cmd.Env = cliEnv(c.DockerHost)
+	out, stderr, err := runWithLog(cmd, c.OnLog)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return contextError(ctx, err)
+	}
+
+	var r struct {
+		Outcome     string
+		Message     string
+		Description string
+	}
+	perr := parseResult(out, &r)
+	if err == nil && perr == nil && r.Outcome == "success" {
+		return nil
+	}
+
+	msg := r.Message
+	if msg == "" && len(stderr) > 0 {
+		msg = stderr[len(stderr)-1]
+	}
+	if err == nil {
+		err = perr
+	}
+	if err == nil {
+		err = fmt.Errorf("outcome `%s`", r.Outcome)
+	}
+	if msg == "" {
+		return fmt.Errorf("devcontainer run-user-commands failed: %w", contextError(ctx, err))
+	}
+	return fmt.Errorf("devcontainer run-user-commands failed: %s: %w", strings.TrimSuffix(msg, "."), contextError(ctx, err))
+}
+
+func buildRunUserCommandsOption(c RunUserCommandsConfig) (r []string) {
+	r = append(r, "run-user-commands")
+
+	if c.DockerPath != "" {
+		r = append(r, "--docker-path", c.DockerPath)
+	}
+
+	if c.DockerComposePath != "" {
+		r = append(r, "--docker-compose-path", c.DockerComposePath)
+	}
+
+	if c.WorkspaceFolder != "" {
+		r = append(r, "--workspace-folder", c.WorkspaceFolder)
+	}
+
+	if c.ContainerId != "" {
+		r = append(r, "--container-id", c.ContainerId)
+	}
+
+	return
+}
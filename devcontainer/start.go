@@ -0,0 +1,49 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// config of [Start]
+type StartConfig struct {
+	// docker path (if needed)
+	DockerPath string
+	// docker compose path (if needed).
+	// default: `compose` subcommand of DockerPath
+	DockerComposePath string
+	// address of the docker daemon for the CLI (DOCKER_HOST, if needed)
+	DockerHost string
+
+	ContainerId        string
+	ComposeProjectName string
+}
+
+// start the containers stopped by [Stop]
+func Start(c StartConfig) error {
+	return StartContext(context.Background(), c)
+}
+
+// same as [Start]. the command is killed when ctx is done
+func StartContext(ctx context.Context, c StartConfig) error {
+	var dpath string
+	if c.DockerPath != "" {
+		dpath = c.DockerPath
+	} else {
+		dpath = "docker"
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case c.ComposeProjectName != "":
+		cmd = composeCommand(ctx, dpath, c.DockerComposePath, "-p", c.ComposeProjectName, "start")
+	case c.ContainerId != "":
+		cmd = commandContext(ctx, dpath, "start", c.ContainerId)
+	default:
+		return errors.New("cannnot find any compose project or container")
+	}
+
+	cmd.Env = cliEnv(c.DockerHost)
+	return contextError(ctx, cmd.Run())
+}
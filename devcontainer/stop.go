@@ -0,0 +1,50 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// config of [Stop]
+type StopConfig struct {
+	// docker path (if needed)
+	DockerPath string
+	// docker compose path (if needed).
+	// default: `compose` subcommand of DockerPath
+	DockerComposePath string
+	// address of the docker daemon for the CLI (DOCKER_HOST, if needed)
+	DockerHost string
+
+	ContainerId        string
+	ComposeProjectName string
+}
+
+// stop the containers without removing them.
+// they can be started again by `devcontainer up`.
+func Stop(c StopConfig) error {
+	return StopContext(context.Background(), c)
+}
+
+// same as [Stop]. the command is killed when ctx is done
+func StopContext(ctx context.Context, c StopConfig) error {
+	var dpath string
+	if c.DockerPath != "" {
+		dpath = c.DockerPath
+	} else {
+		dpath = "docker"
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case c.ComposeProjectName != "":
+		cmd = composeCommand(ctx, dpath, c.DockerComposePath, "-p", c.ComposeProjectName, "stop")
+	case c.ContainerId != "":
+		cmd = commandContext(ctx, dpath, "stop", c.ContainerId)
+	default:
+		return errors.New("cannnot find any compose project or container")
+	}
+
+	cmd.Env = cliEnv(c.DockerHost)
+	return contextError(ctx, cmd.Run())
+}
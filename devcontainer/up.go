@@ -1,11 +1,16 @@
 package devcontainer
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
+	"sync"
 )
 
 // config of [Up]
@@ -14,6 +19,8 @@ type UpConfig struct {
 	DockerPath string
 	// docker compose path (if needed)
 	DockerComposePath string
+	// address of the docker daemon for the CLI (DOCKER_HOST, if needed)
+	DockerHost string
 	// workspace path.
 	// default: current directory
 	WorkspaceFolder string
@@ -27,8 +34,25 @@ type UpConfig struct {
 	AdditionalMounts []MountConfig
 	// additional features
 	AdditionalFeatures map[string]map[string]any
+	// remove the container of the workspace if it exists, and create new one
+	RemoveExistingContainer bool
+	// build images without docker build cache
+	BuildNoCache bool
+	// fail if the container of the workspace does not exist
+	ExpectExistingContainer bool
+	// called with each line of stdout/stderr while the command is running (if needed).
+	// it may be called from multiple goroutines at the same time.
+	OnLog func(stream LogStream, line string)
 }
 
+// output stream of a log line
+type LogStream string
+
+const (
+	Stdout LogStream = "stdout"
+	Stderr LogStream = "stderr"
+)
+
 type UpResult struct {
 	// command result
 	Outcome               string
@@ -38,27 +62,161 @@ type UpResult struct {
 	RemoteWorkspaceFolder string
 }
 
+// failure of `devcontainer up`.
+// fields other than ExitCode and Stderr come from the result JSON of the CLI, and may be empty
+// when the CLI exited without it.
+type UpError struct {
+	// "error" on failure reported by the CLI
+	Outcome string
+	// what failed (like "Command failed: docker buildx build ...")
+	Message string
+	// summary of the failure (like "An error occurred building the image.")
+	Description string
+	// exit code of the CLI. -1 when it was killed
+	ExitCode int
+	// last lines of stderr (at most [StderrTailLines]).
+	// the failed feature or Dockerfile step is usually here
+	Stderr []string
+	// underlying error (like *exec.ExitError, or context.Canceled when ctx is done)
+	Err error `json:"-"`
+}
+
+// max lines of [UpError.Stderr]
+const StderrTailLines = 50
+
+func (e *UpError) Error() string {
+	msg := "devcontainer up failed"
+	if e.Description != "" {
+		msg += ": " + strings.TrimSuffix(e.Description, ".")
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.ExitCode != 0 {
+		msg += fmt.Sprintf(" (exit code %d)", e.ExitCode)
+	}
+	return msg
+}
+
+func (e *UpError) Unwrap() error {
+	return e.Err
+}
+
 // start devcontainer with [UpConfig]
 func Up(c UpConfig) (r UpResult, err error) {
-	out, err := exec.Command(devcontainerCliPath, buildUpOption(c)...).Output()
-	if err != nil {
-		return
+	return UpContext(context.Background(), c)
+}
+
+// same as [Up]. when ctx is done, the CLI and processes started by it (like image pulls and builds) are killed,
+// and the error wraps ctx.Err(). containers created so far are left; remove them by [DownContext] with WorkspaceFolder.
+// failures of the CLI are returned as [*UpError].
+func UpContext(ctx context.Context, c UpConfig) (UpResult, error) {
+	cmd := commandContext(ctx, devcontainerCliPath, buildUpOption(c)...)
+	cmd.Env = cliEnv(c.DockerHost)
+	out, stderr, err := runWithLog(cmd, c.OnLog)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		// not started
+		return UpResult{}, contextError(ctx, err)
+	}
+
+	// the CLI prints the result JSON on failure too
+	var r struct {
+		UpResult
+		Message     string
+		Description string
 	}
+	perr := parseResult(out, &r)
+	if err == nil && perr == nil && r.Outcome == "success" {
+		return r.UpResult, nil
+	}
+
+	ue := &UpError{
+		Outcome:     r.Outcome,
+		Message:     r.Message,
+		Description: r.Description,
+		Stderr:      stderr,
+		Err:         contextError(ctx, err),
+	}
+	if exitErr != nil {
+		ue.ExitCode = exitErr.ExitCode()
+	}
+	if ue.Err == nil && perr != nil {
+		ue.Err = perr
+	}
+	return r.UpResult, ue
+}
+
+// decode the result JSON in stdout of the CLI
+func parseResult(out []byte, v any) error {
 	js := string(out)
 	jindex := strings.Index(js, "{")
 	if jindex == -1 {
-		err = errors.New("not found result json")
-		return
+		return errors.New("not found result json")
 	}
-	js = js[jindex:]
-	if err = json.Unmarshal([]byte(js), &r); err != nil {
-		return
+	return json.Unmarshal([]byte(js[jindex:]), v)
+}
+
+// run cmd and pass each output line to onLog (if not nil).
+// returns whole stdout and the last StderrTailLines lines of stderr.
+func runWithLog(cmd *exec.Cmd, onLog func(stream LogStream, line string)) ([]byte, []string, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
 	}
-	if r.Outcome != "success" {
-		err = errors.New("failed to start container...")
-		return
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	var out bytes.Buffer
+	var tail []string
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		readLines(io.TeeReader(stdout, &out), Stdout, func(stream LogStream, line string) {
+			if onLog != nil {
+				onLog(stream, line)
+			}
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		readLines(stderr, Stderr, func(stream LogStream, line string) {
+			if len(tail) == StderrTailLines {
+				tail = tail[1:]
+			}
+			tail = append(tail, line)
+			if onLog != nil {
+				onLog(stream, line)
+			}
+		})
+	}()
+	wg.Wait()
+
+	err = cmd.Wait()
+	return out.Bytes(), tail, err
+}
+
+func readLines(r io.Reader, stream LogStream, onLog func(stream LogStream, line string)) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			onLog(stream, strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
 	}
-	return
 }
 
 func buildUpOption(c UpConfig) (r []string) {
@@ -104,6 +262,18 @@ func buildUpOption(c UpConfig) (r []string) {
 		r = append(r, "--additional-features", string(js))
 	}
 
+	if c.RemoveExistingContainer {
+		r = append(r, "--remove-existing-container")
+	}
+
+	if c.BuildNoCache {
+		r = append(r, "--build-no-cache")
+	}
+
+	if c.ExpectExistingContainer {
+		r = append(r, "--expect-existing-container")
+	}
+
 	r = append(r, "up")
 
 	return
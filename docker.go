@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/0x5341/devco/docker"
+)
+
+var errContainerNotFound = docker.ErrNotFound
+
+// returns errContainerNotFound if the container does not exist
+func inspectContainer(cid string) (docker.ContainerJSON, error) {
+	cli, err := dockerClient()
+	if err != nil {
+		return docker.ContainerJSON{}, err
+	}
+	return cli.ContainerInspect(context.Background(), cid)
+}
+
+// command of the runtime CLI (docker or podman)
+func runtimeCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, containerRuntime.DockerPath, args...)
+	if containerRuntime.Socket != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+containerRuntime.Socket)
+	}
+	return cmd
+}
+
+// run command in the container as user and return stdout
+func execInContainer(ctx context.Context, cid string, user string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := runtimeCommand(ctx, append([]string{"exec", "-u", user, cid}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error `docker exec`: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,156 @@
+// Package docker is a small client of the Docker Engine API.
+// it covers only what devco needs, and talks to the daemon without the docker CLI.
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// used when DOCKER_HOST is not set
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// client of the Docker Engine API
+type Client struct {
+	// DOCKER_HOST style address (`unix:///path` or `tcp://host:port`)
+	host string
+	base string
+	http *http.Client
+}
+
+// create client of host (`unix:///var/run/docker.sock`, `tcp://127.0.0.1:2375`).
+// empty host means DOCKER_HOST, or [DefaultHost] if it is not set.
+// TLS (DOCKER_TLS_VERIFY) is not supported.
+func NewClient(host string) (*Client, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = DefaultHost
+	}
+
+	scheme, addr, ok := strings.Cut(host, "://")
+	if !ok {
+		return nil, fmt.Errorf("invalid docker host `%s`", host)
+	}
+
+	c := &Client{host: host}
+	switch scheme {
+	case "unix":
+		c.base = "http://docker"
+		c.http = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", addr)
+			},
+		}}
+	case "tcp", "http":
+		c.base = "http://" + addr
+		c.http = &http.Client{Transport: &http.Transport{}}
+	default:
+		return nil, fmt.Errorf("unsupported docker host `%s` (unix or tcp)", host)
+	}
+	return c, nil
+}
+
+// address of the daemon
+func (c *Client) Host() string {
+	return c.host
+}
+
+// send request and return response with 2xx status.
+// other status codes are returned as [*APIError].
+func (c *Client) do(ctx context.Context, op string, method string, p string, query url.Values, body any) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(b)
+	}
+
+	u := c.base + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &ConnectionError{Host: c.host, Err: err}
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, newAPIError(op, res)
+	}
+	return res, nil
+}
+
+// send request and decode JSON response into v (if not nil)
+func (c *Client) doJSON(ctx context.Context, op string, method string, p string, query url.Values, body any, v any) error {
+	res, err := c.do(ctx, op, method, p, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if v == nil {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: error decode response: %s", op, err)
+	}
+	return nil
+}
+
+// check the daemon is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	return c.doJSON(ctx, "ping", http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// `{"label": ["a=b"]}` -> `filters` query parameter
+func encodeFilters(f map[string][]string) string {
+	m := make(map[string]map[string]bool)
+	for k, vs := range f {
+		m[k] = make(map[string]bool)
+		for _, v := range vs {
+			m[k][v] = true
+		}
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// part of `GET /version` result
+type Version struct {
+	Version    string
+	ApiVersion string
+	Os         string
+	Arch       string
+}
+
+func (c *Client) Version(ctx context.Context) (Version, error) {
+	var r Version
+	err := c.doJSON(ctx, "version", http.MethodGet, "/version", nil, nil, &r)
+	return r, err
+}
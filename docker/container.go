@@ -0,0 +1,255 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// part of `GET /containers/{id}/json` result
+type ContainerJSON struct {
+	Id    string
+	Name  string
+	State struct {
+		// "created", "running", "paused", "restarting", "removing", "exited" or "dead"
+		Status     string
+		Running    bool
+		ExitCode   int
+		Error      string
+		StartedAt  string
+		FinishedAt string
+	}
+	Config struct {
+		Image  string
+		User   string
+		Tty    bool
+		Labels map[string]string
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string
+			GlobalIPv6Address string
+		}
+		Ports map[string][]PortBinding
+	}
+}
+
+// published port of the container
+type PortBinding struct {
+	HostIp   string
+	HostPort string
+}
+
+// first IPAddress of the container's networks
+func (c ContainerJSON) IPAddress() (string, error) {
+	for _, n := range c.NetworkSettings.Networks {
+		if n.IPAddress != "" {
+			return n.IPAddress, nil
+		}
+	}
+	return "", fmt.Errorf("container `%s` has no IPAddress (status: %s)", c.Id, c.State.Status)
+}
+
+func (c *Client) ContainerInspect(ctx context.Context, id string) (ContainerJSON, error) {
+	var r ContainerJSON
+	err := c.doJSON(ctx, "inspect container", http.MethodGet, "/containers/"+url.PathEscape(id)+"/json", nil, nil, &r)
+	return r, err
+}
+
+// element of `GET /containers/json` result
+type Container struct {
+	Id     string
+	Names  []string
+	Image  string
+	State  string
+	Status string
+	Labels map[string]string
+}
+
+// options of [Client.ContainerList]
+type ContainerListOptions struct {
+	// include stopped containers
+	All bool
+	// like `{"label": ["com.docker.compose.project=foo"]}`
+	Filters map[string][]string
+}
+
+func (c *Client) ContainerList(ctx context.Context, o ContainerListOptions) ([]Container, error) {
+	q := url.Values{}
+	if o.All {
+		q.Set("all", "true")
+	}
+	if len(o.Filters) > 0 {
+		q.Set("filters", encodeFilters(o.Filters))
+	}
+
+	var r []Container
+	err := c.doJSON(ctx, "list containers", http.MethodGet, "/containers/json", q, nil, &r)
+	return r, err
+}
+
+// stop the container. timeout is seconds to wait before killing it (nil for the default).
+// stopping a stopped container is not an error.
+func (c *Client) ContainerStop(ctx context.Context, id string, timeout *int) error {
+	q := url.Values{}
+	if timeout != nil {
+		q.Set("t", strconv.Itoa(*timeout))
+	}
+	err := c.doJSON(ctx, "stop container", http.MethodPost, "/containers/"+url.PathEscape(id)+"/stop", q, nil, nil)
+	if errors.Is(err, ErrNotModified) {
+		return nil
+	}
+	return err
+}
+
+// start the container. starting a running container is not an error.
+func (c *Client) ContainerStart(ctx context.Context, id string) error {
+	err := c.doJSON(ctx, "start container", http.MethodPost, "/containers/"+url.PathEscape(id)+"/start", nil, nil, nil)
+	if errors.Is(err, ErrNotModified) {
+		return nil
+	}
+	return err
+}
+
+// options of [Client.ContainerRemove]
+type ContainerRemoveOptions struct {
+	// kill the container if it is running
+	Force bool
+	// remove anonymous volumes of the container
+	RemoveVolumes bool
+}
+
+func (c *Client) ContainerRemove(ctx context.Context, id string, o ContainerRemoveOptions) error {
+	q := url.Values{}
+	if o.Force {
+		q.Set("force", "true")
+	}
+	if o.RemoveVolumes {
+		q.Set("v", "true")
+	}
+	return c.doJSON(ctx, "remove container", http.MethodDelete, "/containers/"+url.PathEscape(id), q, nil, nil)
+}
+
+// options of [Client.ContainerLogs]
+type ContainerLogsOptions struct {
+	Stdout bool
+	Stderr bool
+	// keep streaming new logs
+	Follow bool
+	// number of lines from the end ("all" or like "100"). default: all
+	Tail string
+	// only logs after the time (if not zero)
+	Since      time.Time
+	Timestamps bool
+}
+
+// logs of the container. close the result after use.
+// the stream is multiplexed unless the container has a TTY ([ContainerJSON].Config.Tty);
+// split it with [DemuxLogs].
+func (c *Client) ContainerLogs(ctx context.Context, id string, o ContainerLogsOptions) (io.ReadCloser, error) {
+	q := url.Values{}
+	q.Set("stdout", strconv.FormatBool(o.Stdout))
+	q.Set("stderr", strconv.FormatBool(o.Stderr))
+	if o.Follow {
+		q.Set("follow", "true")
+	}
+	if o.Tail != "" {
+		q.Set("tail", o.Tail)
+	}
+	if !o.Since.IsZero() {
+		q.Set("since", strconv.FormatInt(o.Since.Unix(), 10))
+	}
+	if o.Timestamps {
+		q.Set("timestamps", "true")
+	}
+
+	res, err := c.do(ctx, "container logs", http.MethodGet, "/containers/"+url.PathEscape(id)+"/logs", q, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// copy multiplexed log stream of [Client.ContainerLogs] to stdout and stderr
+func DemuxLogs(stdout io.Writer, stderr io.Writer, r io.Reader) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		// header: stream type (1 byte), padding (3 bytes), size (big endian uint32)
+		var w io.Writer
+		switch header[0] {
+		case 0, 1:
+			w = stdout
+		case 2:
+			w = stderr
+		default:
+			return fmt.Errorf("invalid log stream type %d", header[0])
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// part of `GET /containers/{id}/stats` result
+type Stats struct {
+	Read        time.Time `json:"read"`
+	CPUStats    CPUStats  `json:"cpu_stats"`
+	PreCPUStats CPUStats  `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+}
+
+type CPUStats struct {
+	CPUUsage struct {
+		TotalUsage uint64 `json:"total_usage"`
+	} `json:"cpu_usage"`
+	SystemCPUUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs     uint32 `json:"online_cpus"`
+}
+
+// CPU usage in percent (100% per core), same as `docker stats`
+func (s Stats) CPUPercent() float64 {
+	cpu := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	system := float64(s.CPUStats.SystemCPUUsage) - float64(s.PreCPUStats.SystemCPUUsage)
+	if cpu <= 0 || system <= 0 {
+		return 0
+	}
+	return cpu / system * float64(s.CPUStats.OnlineCPUs) * 100
+}
+
+// memory usage without page cache, same as `docker stats`
+func (s Stats) MemoryUsage() uint64 {
+	cache := s.MemoryStats.Stats["inactive_file"]
+	if cache > s.MemoryStats.Usage {
+		return s.MemoryStats.Usage
+	}
+	return s.MemoryStats.Usage - cache
+}
+
+// current resource usage of the running container
+func (c *Client) ContainerStats(ctx context.Context, id string) (Stats, error) {
+	q := url.Values{}
+	q.Set("stream", "false")
+
+	var r Stats
+	err := c.doJSON(ctx, "container stats", http.MethodGet, "/containers/"+url.PathEscape(id)+"/stats", q, nil, &r)
+	return r, err
+}
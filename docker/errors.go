@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// container, network or other object does not exist (404)
+	ErrNotFound = errors.New("not found")
+	// the object is in a conflicting state, like removing a running container (409)
+	ErrConflict = errors.New("conflict")
+	// nothing to do, like starting a running container (304)
+	ErrNotModified = errors.New("not modified")
+	// the daemon can not be reached
+	ErrDaemonUnavailable = errors.New("docker daemon is not available")
+)
+
+// error response of the Engine API.
+// errors.Is matches [ErrNotFound], [ErrConflict] and [ErrNotModified] by StatusCode.
+type APIError struct {
+	// operation (like "inspect container")
+	Op         string
+	StatusCode int
+	// message from the daemon
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s (status %d)", e.Op, e.Message, e.StatusCode)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrNotModified:
+		return e.StatusCode == http.StatusNotModified
+	}
+	return false
+}
+
+func newAPIError(op string, res *http.Response) *APIError {
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+
+	msg := strings.TrimSpace(string(b))
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(b, &body) == nil && body.Message != "" {
+		msg = body.Message
+	}
+	if msg == "" {
+		msg = http.StatusText(res.StatusCode)
+	}
+	return &APIError{Op: op, StatusCode: res.StatusCode, Message: msg}
+}
+
+// failure to connect to the daemon.
+// errors.Is matches [ErrDaemonUnavailable].
+type ConnectionError struct {
+	Host string
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("cannot connect to docker daemon at `%s`: %s", e.Host, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConnectionError) Is(target error) bool {
+	return target == ErrDaemonUnavailable
+}
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// element of `GET /events` stream
+type Event struct {
+	// "container", "network", "image", ...
+	Type string
+	// "start", "die", "stop", "destroy", ...
+	Action string
+	Actor  struct {
+		// id of the object
+		ID         string
+		Attributes map[string]string
+	}
+	// unix time in nanoseconds
+	TimeNano int64 `json:"timeNano"`
+}
+
+func (e Event) Time() time.Time {
+	return time.Unix(0, e.TimeNano)
+}
+
+// options of [Client.Events]
+type EventsOptions struct {
+	// only events after the time (if not zero)
+	Since time.Time
+	// like `{"type": ["container"], "event": ["start", "die"]}`
+	Filters map[string][]string
+}
+
+// stream events of the daemon until ctx is done or the connection is lost.
+// the error channel receives one error (nil when ctx is done) and then both channels are closed.
+func (c *Client) Events(ctx context.Context, o EventsOptions) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(events)
+
+		q := url.Values{}
+		if !o.Since.IsZero() {
+			q.Set("since", strconv.FormatInt(o.Since.Unix(), 10))
+		}
+		if len(o.Filters) > 0 {
+			q.Set("filters", encodeFilters(o.Filters))
+		}
+
+		res, err := c.do(ctx, "events", http.MethodGet, "/events", q, nil)
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer res.Body.Close()
+
+		dec := json.NewDecoder(res.Body)
+		for {
+			var e Event
+			if err := dec.Decode(&e); err != nil {
+				if ctx.Err() != nil {
+					errc <- nil
+				} else if errors.Is(err, io.EOF) {
+					errc <- fmt.Errorf("events: stream closed by daemon")
+				} else {
+					errc <- fmt.Errorf("events: error decode event: %s", err)
+				}
+				return
+			}
+
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				errc <- nil
+				return
+			}
+		}
+	}()
+
+	return events, errc
+}
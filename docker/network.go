@@ -0,0 +1,35 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// element of `GET /networks` result
+type Network struct {
+	Id     string
+	Name   string
+	Labels map[string]string
+}
+
+// options of [Client.NetworkList]
+type NetworkListOptions struct {
+	// like `{"label": ["com.docker.compose.project=foo"]}`
+	Filters map[string][]string
+}
+
+func (c *Client) NetworkList(ctx context.Context, o NetworkListOptions) ([]Network, error) {
+	q := url.Values{}
+	if len(o.Filters) > 0 {
+		q.Set("filters", encodeFilters(o.Filters))
+	}
+
+	var r []Network
+	err := c.doJSON(ctx, "list networks", http.MethodGet, "/networks", q, nil, &r)
+	return r, err
+}
+
+func (c *Client) NetworkRemove(ctx context.Context, id string) error {
+	return c.doJSON(ctx, "remove network", http.MethodDelete, "/networks/"+url.PathEscape(id), nil, nil, nil)
+}
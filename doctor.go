@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x5341/devco/devcontainer"
+)
+
+// oldest versions devco works with.
+// git: `worktree remove`. docker: compose v2 plugin. devcontainer: `read-configuration --include-merged-configuration`
+var (
+	minGitVersion          = [3]int{2, 17, 0}
+	minDockerVersion       = [3]int{20, 10, 0}
+	minPodmanVersion       = [3]int{4, 0, 0}
+	minComposeVersion      = [3]int{2, 0, 0}
+	minDevcontainerVersion = [3]int{0, 50, 0}
+)
+
+const doctorTimeout = 10 * time.Second
+
+type checkStatus string
+
+const (
+	checkOk      checkStatus = "ok"
+	checkWarning checkStatus = "warning"
+	checkError   checkStatus = "error"
+)
+
+// result of a prerequisite check
+type checkResult struct {
+	Name   string
+	Status checkStatus
+	// what was found (like version), or what is wrong
+	Detail string
+	// how to fix it. empty when Status is ok
+	Fix string `json:",omitempty"`
+}
+
+// check prerequisites of devco. st may be nil when the store can not be opened.
+func checkEnvironment(datadir string, st projectStore) []checkResult {
+	var r []checkResult
+	r = append(r, checkGit())
+	r = append(r, checkRuntimeCli())
+	r = append(r, checkDaemon())
+	r = append(r, checkCompose())
+	r = append(r, checkDevcontainerCli())
+	r = append(r, checkDatadir(datadir))
+	if st != nil {
+		r = append(r, checkProjects(st)...)
+	}
+	return r
+}
+
+// first `x.y` or `x.y.z` in s
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+func parseVersion(s string) ([3]int, bool) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return [3]int{}, false
+	}
+	var v [3]int
+	for i := range 3 {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	return v, true
+}
+
+func versionString(v [3]int) string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// compare version in output with min
+func checkVersion(name string, output string, min [3]int, fix string) checkResult {
+	output = strings.TrimSpace(output)
+	v, ok := parseVersion(output)
+	if !ok {
+		return checkResult{Name: name, Status: checkWarning, Detail: fmt.Sprintf("unknown version `%s`", output), Fix: fix}
+	}
+	if slices.Compare(v[:], min[:]) < 0 {
+		return checkResult{Name: name, Status: checkError, Detail: fmt.Sprintf("%s is older than %s", versionString(v), versionString(min)), Fix: fix}
+	}
+	return checkResult{Name: name, Status: checkOk, Detail: output}
+}
+
+// run command and return combined output. the command is killed after doctorTimeout
+func runCheckCommand(cmd *exec.Cmd) (string, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	t := time.AfterFunc(doctorTimeout, func() { cmd.Process.Kill() })
+	defer t.Stop()
+
+	err := cmd.Wait()
+	return out.String(), err
+}
+
+func checkGit() checkResult {
+	const fix = "install git 2.17 or later (like `sudo apt install git`)"
+	out, err := runCheckCommand(exec.Command("git", "--version"))
+	if err != nil {
+		return checkResult{Name: "git", Status: checkError, Detail: commandError(err, out), Fix: fix}
+	}
+	return checkVersion("git", out, minGitVersion, fix)
+}
+
+func checkRuntimeCli() checkResult {
+	name := containerRuntime.DockerPath
+	min := minDockerVersion
+	fix := "install Docker Engine 20.10 or later (https://docs.docker.com/engine/install/), or set Runtime.DockerPath in config"
+	if containerRuntime.Type == runtimePodman {
+		min = minPodmanVersion
+		fix = "install podman 4.0 or later (like `sudo dnf install podman`), or set Runtime.DockerPath in config"
+	}
+
+	out, err := runCheckCommand(runtimeCommand(context.Background(), "--version"))
+	if err != nil {
+		return checkResult{Name: name, Status: checkError, Detail: commandError(err, out), Fix: fix}
+	}
+	return checkVersion(name, out, min, fix)
+}
+
+func checkDaemon() checkResult {
+	name := containerRuntime.Type + " daemon"
+	fix := "start the docker daemon (like `sudo systemctl start docker`) and check your user can access its socket (`docker` group), or set Runtime.Socket in config"
+	if containerRuntime.Type == runtimePodman {
+		fix = "start the podman API service (`systemctl --user enable --now podman.socket`), or set Runtime.Socket in config"
+	}
+
+	cli, err := dockerClient()
+	if err != nil {
+		return checkResult{Name: name, Status: checkError, Detail: err.Error(), Fix: fix}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	v, err := cli.Version(ctx)
+	if err != nil {
+		return checkResult{Name: name, Status: checkError, Detail: err.Error(), Fix: fix}
+	}
+	return checkResult{Name: name, Status: checkOk, Detail: fmt.Sprintf("%s (API %s) at %s", v.Version, v.ApiVersion, cli.Host())}
+}
+
+// compose is needed only by devcontainers using docker compose, so problems are warnings
+func checkCompose() checkResult {
+	const name = "docker compose"
+	fix := "install the docker compose plugin v2 (https://docs.docker.com/compose/install/linux/), or set Runtime.DockerComposePath in config"
+	if containerRuntime.Type == runtimePodman {
+		fix = "install podman-compose or docker-compose v2, and set Runtime.DockerComposePath in config"
+	}
+
+	var cmd *exec.Cmd
+	if containerRuntime.DockerComposePath != "" {
+		cmd = exec.Command(containerRuntime.DockerComposePath, "version")
+	} else {
+		cmd = runtimeCommand(context.Background(), "compose", "version")
+	}
+
+	out, err := runCheckCommand(cmd)
+	if err != nil {
+		return checkResult{Name: name, Status: checkWarning, Detail: commandError(err, out), Fix: fix}
+	}
+	r := checkVersion(name, out, minComposeVersion, fix)
+	if r.Status == checkError {
+		r.Status = checkWarning
+	}
+	return r
+}
+
+func checkDevcontainerCli() checkResult {
+	const name = "devcontainer CLI"
+	const fix = "install the devcontainer CLI 0.50 or later (`npm install -g @devcontainers/cli`)"
+
+	path, err := devcontainer.LookupDevcontainer()
+	if err != nil {
+		return checkResult{Name: name, Status: checkError, Detail: err.Error(), Fix: fix}
+	}
+	out, err := devcontainer.CliVersion(path)
+	if err != nil {
+		return checkResult{Name: name, Status: checkError, Detail: err.Error(), Fix: fix}
+	}
+	return checkVersion(name, out, minDevcontainerVersion, fix)
+}
+
+func checkDatadir(datadir string) checkResult {
+	const name = "datadir"
+	fix := fmt.Sprintf("make `%s` writable by this user, or use another directory with --datadir", datadir)
+
+	f, err := os.CreateTemp(datadir, ".doctor-*")
+	if err != nil {
+		return checkResult{Name: name, Status: checkError, Detail: err.Error(), Fix: fix}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return checkResult{Name: name, Status: checkOk, Detail: datadir + " is writable"}
+}
+
+// every project path is still a git repository
+func checkProjects(st projectStore) []checkResult {
+	js, err := st.Load()
+	if err != nil {
+		return []checkResult{{Name: "projects", Status: checkError, Detail: err.Error(), Fix: "check the store in datadir (projects.json or devco.db)"}}
+	}
+
+	var r []checkResult
+	for _, pjname := range slices.Sorted(maps.Keys(js)) {
+		name := fmt.Sprintf("project `%s`", pjname)
+		p := js[pjname].Path
+		fix := fmt.Sprintf("restore the git repository at `%s`, or delete the project and add it again", p)
+
+		if !exist(p) {
+			r = append(r, checkResult{Name: name, Status: checkError, Detail: fmt.Sprintf("`%s` does not exist", p), Fix: fix})
+			continue
+		}
+		out, err := runCheckCommand(exec.Command("git", "-C", p, "rev-parse", "--git-dir"))
+		if err != nil {
+			r = append(r, checkResult{Name: name, Status: checkError, Detail: fmt.Sprintf("`%s` is not a git repository: %s", p, commandError(err, out)), Fix: fix})
+			continue
+		}
+		r = append(r, checkResult{Name: name, Status: checkOk, Detail: p})
+	}
+	return r
+}
+
+// error of command with its output
+func commandError(err error, out string) string {
+	if errors.Is(err, exec.ErrNotFound) {
+		return err.Error()
+	}
+	if out = strings.TrimSpace(out); out != "" {
+		return fmt.Sprintf("%s: %s", err, out)
+	}
+	return err.Error()
+}
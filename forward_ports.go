@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+
+	"github.com/0x5341/devco/devcontainer"
+)
+
+// links of the ports declared in devcontainer.json (forwardPorts and portsAttributes).
+// named by label, or `port {N}` without label. a label shared by ports (like a range) gets ` (port {N})`.
+// ports of other compose services and ports with `onAutoForward: ignore` are skipped.
+func declaredLinks(c devcontainer.Configuration) map[string]link {
+	type declared struct {
+		port int
+		attr devcontainer.PortAttributes
+	}
+	var ports []declared
+	labels := make(map[string][]int)
+	for _, p := range c.ForwardPorts {
+		if p.Host != "" && p.Host != "localhost" && p.Host != "127.0.0.1" {
+			log.Printf("forwardPorts `%s:%d` is skipped: only ports of the devcontainer itself can be opened", p.Host, p.Port)
+			continue
+		}
+
+		attr, _ := c.AttributesOf(p.Port)
+		if attr.OnAutoForward == "ignore" {
+			continue
+		}
+		ports = append(ports, declared{port: p.Port, attr: attr})
+		if attr.Label != "" && !slices.Contains(labels[attr.Label], p.Port) {
+			labels[attr.Label] = append(labels[attr.Label], p.Port)
+		}
+	}
+
+	links := make(map[string]link)
+	for _, p := range ports {
+		name := p.attr.Label
+		switch {
+		case name == "":
+			name = fmt.Sprintf("port %d", p.port)
+		case len(labels[name]) > 1:
+			name = fmt.Sprintf("%s (port %d)", name, p.port)
+		}
+		links[name] = link{Port: p.port, Protocol: p.attr.Protocol}
+	}
+	return links
+}
+
+// add declared links to links.
+// links for the same port or with the same name (from plugins) are kept.
+func mergeDeclaredLinks(links map[string]link, declared map[string]link) {
+	for _, name := range slices.Sorted(maps.Keys(declared)) {
+		l := declared[name]
+		if _, ok := links[name]; ok {
+			continue
+		}
+		dup := false
+		for _, existing := range links {
+			if existing.Port == l.Port {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			continue
+		}
+		links[name] = l
+	}
+}
+
+// protocol to talk to port ("http" or "https")
+func (ws projectsJsonWorkspace) portProtocol(port int) string {
+	for _, l := range ws.OpenLinks {
+		if l.Port == port && l.Protocol == "https" {
+			return "https"
+		}
+	}
+	return "http"
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// flock is not available. only create the lock file.
+func lockDatadir(datadir string) (*os.File, error) {
+	file, err := os.OpenFile(path.Join(datadir, "devco.lock"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error open lock file: %s", err)
+	}
+	return file, nil
+}
+
+// flock is not available. only create the lock file.
+func lockDatadirShared(datadir string) (*os.File, bool, error) {
+	file, err := lockDatadir(datadir)
+	return file, false, err
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+)
+
+// take an exclusive lock on datadir so two devco processes never share it.
+// the lock is held until the returned file is closed (or the process exits).
+func lockDatadir(datadir string) (*os.File, error) {
+	file, err := os.OpenFile(path.Join(datadir, "devco.lock"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error open lock file: %s", err)
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("datadir `%s` is used by another devco process", datadir)
+		}
+		return nil, fmt.Errorf("error lock datadir: %s", err)
+	}
+
+	return file, nil
+}
+
+// take a shared lock on datadir for commands that only inspect it (like doctor).
+// inUse is true (and file is nil) when a devco server holds the exclusive lock.
+func lockDatadirShared(datadir string) (file *os.File, inUse bool, err error) {
+	file, err = os.OpenFile(path.Join(datadir, "devco.lock"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, false, fmt.Errorf("error open lock file: %s", err)
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, true, nil
+		}
+		return nil, false, fmt.Errorf("error lock datadir: %s", err)
+	}
+
+	return file, false, nil
+}
@@ -13,6 +13,7 @@ var rootCmd = &cobra.Command{
 	Use:   "devco",
 	Short: "devco, local codespace",
 	Run: func(cmd *cobra.Command, args []string) {
+		initDatadir()
 		log.Printf("started on %s", address)
 		serve(address, data_dir, conf)
 	},
@@ -21,6 +22,13 @@ var rootCmd = &cobra.Command{
 var address string
 var data_dir string
 var config_path string
+var store string
+var shutdown_policy string
+var tls_cert string
+var tls_key string
+var tls_auto bool
+var tls_hosts []string
+var ssh_address string
 
 var conf config
 
@@ -46,8 +54,15 @@ func init() {
 	config_path_default := path.Join(xdg_config, "devco/config.json")
 
 	rootCmd.Flags().StringVarP(&address, "address", "a", ":8000", "address that serve server")
-	rootCmd.Flags().StringVar(&data_dir, "datadir", data_dir_default, "directory that save data")
-	rootCmd.Flags().StringVarP(&config_path, "config", "c", config_path_default, "path to the config file")
+	rootCmd.PersistentFlags().StringVar(&data_dir, "datadir", data_dir_default, "directory that save data")
+	rootCmd.PersistentFlags().StringVarP(&config_path, "config", "c", config_path_default, "path to the config file")
+	rootCmd.Flags().StringVar(&store, "store", "", "backend that saves projects (json or sqlite). overrides config")
+	rootCmd.Flags().StringVar(&tls_cert, "tls-cert", "", "TLS certificate file. overrides config")
+	rootCmd.Flags().StringVar(&tls_key, "tls-key", "", "TLS key file. overrides config")
+	rootCmd.Flags().BoolVar(&tls_auto, "tls-auto", false, "serve HTTPS with certificate signed by local CA in datadir")
+	rootCmd.Flags().StringSliceVar(&tls_hosts, "tls-host", nil, "hostnames and IPs covered by the generated certificate")
+	rootCmd.Flags().StringVar(&ssh_address, "ssh-address", "", "address of SSH gateway to workspaces (like :2222). overrides config")
+	rootCmd.Flags().StringVar(&shutdown_policy, "shutdown-policy", "", "what to do with running containers on exit (down, stop or leave). overrides config")
 
 	cobra.OnInitialize(func() {
 		// check config file
@@ -67,17 +82,37 @@ func init() {
 			}
 		}
 
-		log.Println("start initialize")
-		if !exist(data_dir) {
-			log.Println("setup required")
-			err := setup(data_dir)
-			if err != nil {
-				log.Fatalf("error while setuping: %s", err)
-			}
+		if store != "" {
+			conf.Store = store
+		}
+		if shutdown_policy != "" {
+			conf.ShutdownPolicy = shutdown_policy
+		}
+		if tls_cert != "" || tls_key != "" {
+			conf.TLS.Cert = tls_cert
+			conf.TLS.Key = tls_key
+		}
+		if tls_auto {
+			conf.TLS.Auto = true
+		}
+		conf.TLS.Hosts = append(conf.TLS.Hosts, tls_hosts...)
+		if ssh_address != "" {
+			conf.SSH.Address = ssh_address
 		}
 	})
 }
 
+func initDatadir() {
+	log.Println("start initialize")
+	if !exist(data_dir) {
+		log.Println("setup required")
+		err := setup(data_dir)
+		if err != nil {
+			log.Fatalf("error while setuping: %s", err)
+		}
+	}
+}
+
 func main() {
 	err := rootCmd.Execute()
 	if err != nil {
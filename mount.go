@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/0x5341/devco/devcontainer"
+)
+
+const (
+	mountBind   = "bind"
+	mountVolume = "volume"
+)
+
+// values of variables in Source and Target of mounts
+type mountVars struct {
+	ProjectName   string
+	WorkspaceName string
+	// home directory of the user running devco
+	Home string
+}
+
+// `${name}` in mounts
+var mountVarPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// docker volume names
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// expand `${projectName}`, `${workspaceName}`, `${userHome}` and leading `~` in s
+func (v mountVars) expand(s string) (string, error) {
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		s = "${userHome}" + s[1:]
+	}
+
+	var err error
+	r := mountVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		switch name := m[2 : len(m)-1]; name {
+		case "projectName":
+			return v.ProjectName
+		case "workspaceName":
+			return v.WorkspaceName
+		case "userHome":
+			return v.Home
+		default:
+			if err == nil {
+				err = fmt.Errorf("unknown variable `%s` (projectName, workspaceName or userHome)", m)
+			}
+			return m
+		}
+	})
+	return r, err
+}
+
+// check m without a workspace. used when mounts are declared
+func (m mount) validate() error {
+	switch m.Type {
+	case "", mountBind:
+		if m.External {
+			return fmt.Errorf("mount `%s`: External is only for volume mounts", m.Target)
+		}
+	case mountVolume:
+	default:
+		return fmt.Errorf("mount `%s`: invalid Type `%s` (bind or volume)", m.Target, m.Type)
+	}
+
+	if m.Source == "" || m.Target == "" {
+		return fmt.Errorf("mount `%s`: Source and Target are required", m.Target)
+	}
+
+	// placeholders are enough to find unknown variables and syntax errors
+	_, err := m.resolve(mountVars{ProjectName: "project", WorkspaceName: "workspace", Home: "/home"}, false)
+	return err
+}
+
+// expand variables of m and check the result.
+// checkHost also checks the source of bind mounts exists, otherwise docker creates an empty directory there.
+func (m mount) resolve(v mountVars, checkHost bool) (devcontainer.MountConfig, error) {
+	src, err := v.expand(m.Source)
+	if err != nil {
+		return devcontainer.MountConfig{}, fmt.Errorf("mount `%s`: %s", m.Target, err)
+	}
+	target, err := v.expand(m.Target)
+	if err != nil {
+		return devcontainer.MountConfig{}, fmt.Errorf("mount `%s`: %s", m.Target, err)
+	}
+
+	// `--mount` of the devcontainer CLI is comma separated
+	if strings.Contains(src, ",") || strings.Contains(target, ",") {
+		return devcontainer.MountConfig{}, fmt.Errorf("mount `%s`: Source and Target must not contain `,`", target)
+	}
+	if !path.IsAbs(target) {
+		return devcontainer.MountConfig{}, fmt.Errorf("mount `%s`: Target must be an absolute path", target)
+	}
+
+	r := devcontainer.MountConfig{Source: src, Target: path.Clean(target), External: m.External}
+	if m.Type == mountVolume {
+		r.Type = devcontainer.VolumeMount
+		if !volumeNamePattern.MatchString(src) {
+			return devcontainer.MountConfig{}, fmt.Errorf("mount `%s`: invalid volume name `%s`", target, src)
+		}
+		return r, nil
+	}
+
+	r.Type = devcontainer.BindMount
+	if !path.IsAbs(src) {
+		return devcontainer.MountConfig{}, fmt.Errorf("mount `%s`: Source of bind mount must be an absolute path (or start with `~`)", target)
+	}
+	if checkHost {
+		if _, err := os.Stat(src); err != nil {
+			return devcontainer.MountConfig{}, fmt.Errorf("mount `%s`: Source `%s` is not found on the host", target, src)
+		}
+	}
+	return r, nil
+}
+
+// mounts of the project and the plugins for the workspace, passed to `devcontainer up`.
+// errors are statusError with 400.
+func workspaceMounts(cf config, pj projectsJsonProject, pjname string, wsname string, plugins []string) ([]devcontainer.MountConfig, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, statusErrorf(http.StatusInternalServerError, "error get home directory: %s", err)
+	}
+	v := mountVars{ProjectName: pjname, WorkspaceName: wsname, Home: home}
+
+	var r []devcontainer.MountConfig
+	// target -> who declared it
+	declared := make(map[string]string)
+	add := func(owner string, mounts []mount) error {
+		for _, m := range mounts {
+			mc, err := m.resolve(v, true)
+			if err != nil {
+				return statusErrorf(http.StatusBadRequest, "error %s: %s", owner, err)
+			}
+			if other, ok := declared[mc.Target]; ok {
+				return statusErrorf(http.StatusBadRequest, "error mount `%s` is declared by both %s and %s", mc.Target, other, owner)
+			}
+			declared[mc.Target] = owner
+			r = append(r, mc)
+		}
+		return nil
+	}
+
+	if err := add(fmt.Sprintf("project `%s`", pjname), pj.Mounts); err != nil {
+		return nil, err
+	}
+	for _, name := range plugins {
+		if err := add(fmt.Sprintf("plugin `%s`", name), cf.Plugins[name].Mounts); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// features and links of the selected plugins, merged
+type mergedPlugins struct {
+	// selected plugins, sorted and deduplicated
+	Names    []string
+	Features map[string]map[string]any
+	Links    map[string]link
+}
+
+// merge plugins in name order, so the result does not depend on the order of selection.
+// options of the same feature are merged deeply. the same option or link with different values
+// is a conflict, and returned as statusError with 400 naming both plugins.
+func mergePlugins(cf config, names []string) (mergedPlugins, error) {
+	names = slices.Compact(slices.Sorted(slices.Values(names)))
+	for _, name := range names {
+		if _, ok := cf.Plugins[name]; !ok {
+			return mergedPlugins{}, statusErrorf(http.StatusBadRequest, "error plugin `%s` is not exist", name)
+		}
+	}
+
+	r := mergedPlugins{
+		Names:    names,
+		Features: make(map[string]map[string]any),
+		Links:    make(map[string]link),
+	}
+	// `feature/option/...` -> plugin which set it
+	owners := make(map[string]string)
+	linkOwners := make(map[string]string)
+
+	for _, name := range names {
+		p := cf.Plugins[name]
+
+		for _, id := range slices.Sorted(maps.Keys(p.Features)) {
+			if _, ok := r.Features[id]; !ok {
+				r.Features[id] = make(map[string]any)
+			}
+			if err := mergeOptions(r.Features[id], p.Features[id], id, nil, name, owners); err != nil {
+				return mergedPlugins{}, err
+			}
+		}
+
+		for _, lname := range slices.Sorted(maps.Keys(p.Links)) {
+			l := p.Links[lname]
+			if other, ok := linkOwners[lname]; ok {
+				if !reflect.DeepEqual(r.Links[lname], l) {
+					return mergedPlugins{}, statusErrorf(http.StatusBadRequest, "error plugins `%s` and `%s` conflict: link `%s` is defined differently", other, name, lname)
+				}
+				continue
+			}
+			r.Links[lname] = l
+			linkOwners[lname] = name
+		}
+	}
+	return r, nil
+}
+
+// merge src into dst. nested objects are merged, and other values must be equal.
+// path is the option keys from the feature to dst.
+func mergeOptions(dst map[string]any, src map[string]any, feature string, path []string, plugin string, owners map[string]string) error {
+	for _, k := range slices.Sorted(maps.Keys(src)) {
+		p := append(slices.Clone(path), k)
+		key := feature + "/" + strings.Join(p, "/")
+		v := src[k]
+
+		cur, ok := dst[k]
+		if !ok {
+			dst[k] = cloneOption(v)
+			owners[key] = plugin
+			continue
+		}
+
+		curMap, curIsMap := cur.(map[string]any)
+		vMap, vIsMap := v.(map[string]any)
+		if curIsMap && vIsMap {
+			if err := mergeOptions(curMap, vMap, feature, p, plugin, owners); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(cur, v) {
+			return statusErrorf(http.StatusBadRequest, "error plugins `%s` and `%s` conflict: option `%s` of feature `%s` is `%v` and `%v`", optionOwner(owners, feature, p), plugin, strings.Join(p, "."), feature, cur, v)
+		}
+	}
+	return nil
+}
+
+// plugin which set the option at path, or the object containing it
+func optionOwner(owners map[string]string, feature string, path []string) string {
+	for i := len(path); i > 0; i-- {
+		if o, ok := owners[feature+"/"+strings.Join(path[:i], "/")]; ok {
+			return o
+		}
+	}
+	return ""
+}
+
+// deep copy of option value, so merging never modifies config
+func cloneOption(v any) any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	r := make(map[string]any, len(m))
+	for k, v := range m {
+		r[k] = cloneOption(v)
+	}
+	return r
+}
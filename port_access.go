@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const shareParamName = "devco_share"
+const shareCookieName = "devco_share"
+
+const defaultShareExpiresIn = 24 * time.Hour
+
+// who can access a port of the workspace via devco
+type portVisibility string
+
+const (
+	// authenticated users only (default)
+	visibilityPrivate portVisibility = "private"
+	// authenticated users, and anyone with a share link
+	visibilityShared portVisibility = "shared"
+	// nobody
+	visibilityDisabled portVisibility = "disabled"
+)
+
+func parsePortVisibility(s string) (portVisibility, error) {
+	switch v := portVisibility(s); v {
+	case visibilityPrivate, visibilityShared, visibilityDisabled:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid visibility `%s` (private, shared or disabled)", s)
+	}
+}
+
+func (ws projectsJsonWorkspace) portVisibility(port int) portVisibility {
+	if v, ok := ws.PortVisibility[port]; ok {
+		return v
+	}
+	return visibilityPrivate
+}
+
+// returns error (with status code) if port of the workspace can not be accessed via devco.
+// ports are accessible when declared (links), detected in the container,
+// or their visibility is set explicitly. allowAll skips the check of detection.
+func checkPortAccess(ctx context.Context, ws projectsJsonWorkspace, pjname string, wsname string, port int, scanner *portScanner, allowAll bool) error {
+	if ws.State != stateRunning {
+		return statusErrorf(http.StatusServiceUnavailable, "error workspace `%s` is not running", wsname)
+	}
+
+	v, explicit := ws.PortVisibility[port]
+	if v == visibilityDisabled {
+		return statusErrorf(http.StatusForbidden, "error port %d of workspace `%s` is disabled", port, wsname)
+	}
+	if explicit || allowAll {
+		return nil
+	}
+
+	for _, l := range ws.OpenLinks {
+		if l.Port == port {
+			return nil
+		}
+	}
+
+	res, ok := scanner.get(pjname, wsname)
+	if !ok {
+		var err error
+		res, err = scanner.scan(ctx, pjname, wsname, ws.ContainerId)
+		if err != nil {
+			return fmt.Errorf("error detect ports: %s", err)
+		}
+	}
+	if slices.ContainsFunc(res.Ports, func(d detectedPort) bool { return d.Port == port }) {
+		return nil
+	}
+
+	return statusErrorf(http.StatusForbidden, "error port %d of workspace `%s` is not detected nor declared", port, wsname)
+}
+
+// signed expiring links to shared ports.
+// a link works only while the port is still shared, so changing the visibility revokes it.
+type portShares struct {
+	key []byte
+	st  projectStore
+}
+
+func newPortShares(datadir string, st projectStore) (*portShares, error) {
+	key, err := loadOrCreateKey(path.Join(datadir, "session.key"))
+	if err != nil {
+		return nil, err
+	}
+	return &portShares{key: key, st: st}, nil
+}
+
+func (s *portShares) sign(pjname string, wsname string, port int, exp int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	// prefix so a share token never matches a session signature
+	fmt.Fprintf(mac, "share\x00%s\x00%s\x00%d\x00%d", pjname, wsname, port, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// `{expires}.{signature}`
+func (s *portShares) token(pjname string, wsname string, port int, expires time.Time) string {
+	exp := expires.Unix()
+	return strconv.FormatInt(exp, 10) + "." + s.sign(pjname, wsname, port, exp)
+}
+
+func (s *portShares) verify(token string, pjname string, wsname string, port int) (time.Time, bool) {
+	expstr, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	exp, err := strconv.ParseInt(expstr, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return time.Time{}, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(pjname, wsname, port, exp))) {
+		return time.Time{}, false
+	}
+	return time.Unix(exp, 0), true
+}
+
+// token in query (first access of the link) or cookie (following requests)
+func shareToken(r *http.Request) (token string, fromQuery bool) {
+	if t := r.URL.Query().Get(shareParamName); t != "" {
+		return t, true
+	}
+	if c, err := r.Cookie(shareCookieName); err == nil {
+		return c.Value, false
+	}
+	return "", false
+}
+
+func hasShareToken(r *http.Request) bool {
+	t, _ := shareToken(r)
+	return t != ""
+}
+
+// r carries a valid share token for the port, and the port is still shared.
+// the token in query is saved in cookie scoped to cookiePath, so pages can load their assets.
+func (s *portShares) allow(w http.ResponseWriter, r *http.Request, pjname string, wsname string, port int, cookiePath string) bool {
+	token, fromQuery := shareToken(r)
+	if token == "" {
+		return false
+	}
+	exp, ok := s.verify(token, pjname, wsname, port)
+	if !ok {
+		return false
+	}
+
+	js, err := s.st.Load()
+	if err != nil {
+		return false
+	}
+	ws, ok := js[pjname].Workspaces[wsname]
+	if !ok || ws.portVisibility(port) != visibilityShared {
+		return false
+	}
+
+	if fromQuery {
+		http.SetCookie(w, &http.Cookie{
+			Name:     shareCookieName,
+			Value:    token,
+			Path:     cookiePath,
+			Expires:  exp,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return true
+}
+
+// `/port/{pid}/{wid}/{port}/...` -> pid, wid, port
+func parsePortPath(p string) (string, string, int, bool) {
+	rest, ok := strings.CutPrefix(p, "/port/")
+	if !ok {
+		return "", "", 0, false
+	}
+	parts := strings.SplitN(rest, "/", 4)
+	if len(parts) < 4 {
+		return "", "", 0, false
+	}
+	port, err := strconv.ParseUint(parts[2], 10, 16)
+	if err != nil {
+		return "", "", 0, false
+	}
+	return parts[0], parts[1], int(port), true
+}
+
+// URL of the share link. uses the port host when portDomain is set.
+func shareURL(r *http.Request, portDomain string, pjname string, wsname string, port int, token string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	q := url.Values{shareParamName: {token}}.Encode()
+
+	if portDomain != "" {
+		host := fmt.Sprintf("%d-%s-%s.%s", port, wsname, pjname, portDomain)
+		if _, p, err := net.SplitHostPort(r.Host); err == nil {
+			host += ":" + p
+		}
+		return scheme + "://" + host + "/?" + q
+	}
+
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: fmt.Sprintf("/port/%s/%s/%d/", pjname, wsname, port), RawQuery: q}
+	return u.String()
+}
+
+// remove the share token from the query sent to the app
+func stripShareParam(rawQuery string) string {
+	if !strings.Contains(rawQuery, shareParamName) {
+		return rawQuery
+	}
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	q.Del(shareParamName)
+	return q.Encode()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPortScanInterval = 10 * time.Second
+
+// limit of one scan, so a stuck container does not block the scanner or requests
+const portScanTimeout = 5 * time.Second
+
+// prints /proc/net/tcp{,6}, fds of all processes (`/proc/{pid}/fd:` followed by `... -> socket:[inode]`)
+// and `/proc/{pid}/comm:{comm}`. one exec, without a process per fd
+const portScanScript = `cat /proc/net/tcp /proc/net/tcp6 2>/dev/null
+echo ---
+ls -l /proc/[0-9]*/fd 2>/dev/null
+echo ---
+grep -H '' /proc/[0-9]*/comm 2>/dev/null
+true`
+
+// TCP port listening in a container
+type detectedPort struct {
+	Port int
+	// listening address. ports on loopback only (127.0.0.1, ::1) are not reachable from devco
+	Address string
+	// empty if unknown
+	Process string
+	Pid     int
+}
+
+// listening ports of the container
+func scanPorts(ctx context.Context, cid string) ([]detectedPort, error) {
+	ctx, cancel := context.WithTimeout(ctx, portScanTimeout)
+	defer cancel()
+
+	out, err := execInContainer(ctx, cid, "root", "sh", "-c", portScanScript)
+	if err != nil {
+		return nil, err
+	}
+	return parsePortScan(string(out))
+}
+
+func parsePortScan(out string) ([]detectedPort, error) {
+	tcp, rest, _ := strings.Cut(out, "---\n")
+	fds, comms, _ := strings.Cut(rest, "---\n")
+
+	// socket inode -> pid
+	owners := make(map[string]int)
+	pid := 0
+	for _, line := range strings.Split(fds, "\n") {
+		// header of each directory
+		if dir, ok := strings.CutSuffix(line, "/fd:"); ok {
+			pid, _ = strconv.Atoi(strings.TrimPrefix(dir, "/proc/"))
+			continue
+		}
+		_, inode, ok := strings.Cut(line, " -> socket:[")
+		if !ok || pid == 0 {
+			continue
+		}
+		owners[strings.TrimSuffix(inode, "]")] = pid
+	}
+
+	// pid -> comm. comm can contain spaces and colons
+	names := make(map[int]string)
+	for _, line := range strings.Split(comms, "\n") {
+		file, comm, ok := strings.Cut(line, "/comm:")
+		if !ok {
+			continue
+		}
+		if pid, err := strconv.Atoi(strings.TrimPrefix(file, "/proc/")); err == nil {
+			names[pid] = comm
+		}
+	}
+
+	var ports []detectedPort
+	for _, line := range strings.Split(tcp, "\n") {
+		f := strings.Fields(line)
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		if len(f) < 10 || f[0] == "sl" {
+			continue
+		}
+		// 0A = TCP_LISTEN
+		if f[3] != "0A" {
+			continue
+		}
+
+		addrhex, porthex, ok := strings.Cut(f[1], ":")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseUint(porthex, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("error parse port `%s`: %s", porthex, err)
+		}
+		addr, err := parseProcNetAddr(addrhex)
+		if err != nil {
+			return nil, err
+		}
+
+		p := detectedPort{Port: int(port), Address: addr}
+		if pid, ok := owners[f[9]]; ok {
+			p.Process = names[pid]
+			p.Pid = pid
+		}
+
+		// same port on tcp and tcp6
+		if i := slices.IndexFunc(ports, func(d detectedPort) bool { return d.Port == p.Port }); i != -1 {
+			if ports[i].Process == "" {
+				ports[i].Process, ports[i].Pid = p.Process, p.Pid
+			}
+			continue
+		}
+		ports = append(ports, p)
+	}
+
+	slices.SortFunc(ports, func(a, b detectedPort) int { return a.Port - b.Port })
+	return ports, nil
+}
+
+// address in /proc/net/tcp is hex of 32bit words in host byte order (little endian)
+func parseProcNetAddr(s string) (string, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || (len(b) != 4 && len(b) != 16) {
+		return "", fmt.Errorf("error parse address `%s`", s)
+	}
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return net.IP(b).String(), nil
+}
+
+type portScanResult struct {
+	Ports     []detectedPort
+	ScannedAt time.Time
+}
+
+// latest detected ports of running workspaces
+type portScanner struct {
+	mu      sync.Mutex
+	results map[string]portScanResult
+}
+
+func newPortScanner() *portScanner {
+	return &portScanner{results: make(map[string]portScanResult)}
+}
+
+func (s *portScanner) get(pjname string, wsname string) (portScanResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.results[workspaceKey(pjname, wsname)]
+	return r, ok
+}
+
+func (s *portScanner) scan(ctx context.Context, pjname string, wsname string, cid string) (portScanResult, error) {
+	ports, err := scanPorts(ctx, cid)
+	if err != nil {
+		return portScanResult{}, err
+	}
+
+	r := portScanResult{Ports: ports, ScannedAt: time.Now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[workspaceKey(pjname, wsname)] = r
+	return r, nil
+}
+
+// scan all running workspaces and forget the others
+func (s *portScanner) scanAll(ctx context.Context, st projectStore) error {
+	js, err := st.Load()
+	if err != nil {
+		return err
+	}
+
+	running := make(map[string]bool)
+	for pn, p := range js {
+		for wn, ws := range p.Workspaces {
+			if ws.State != stateRunning || ws.ContainerId == "" {
+				continue
+			}
+			running[workspaceKey(pn, wn)] = true
+
+			if _, err := s.scan(ctx, pn, wn, ws.ContainerId); err != nil {
+				log.Printf("error scan ports of workspace `%s` in project `%s`: %s", wn, pn, err)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.results {
+		if !running[k] {
+			delete(s.results, k)
+		}
+	}
+	return nil
+}
+
+// run scanAll every interval until ctx is done
+func (s *portScanner) loop(ctx context.Context, st projectStore, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.scanAll(ctx, st); err != nil {
+				log.Printf("error scan ports: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x5341/devco/devcontainer"
+)
+
+// run PostStartCommands of plugins in order, as RemoteUser of the container (`devcontainer exec`).
+// each line of output is passed to onLog.
+func runPostStartCommands(ctx context.Context, cf config, plugins []string, wspath string, onLog func(stream devcontainer.LogStream, line string), progress func(string)) error {
+	for _, name := range plugins {
+		for _, c := range cf.Plugins[name].PostStartCommands {
+			progress(fmt.Sprintf("run post-start command of plugin `%s`", name))
+
+			stdout := &lineWriter{stream: devcontainer.Stdout, onLog: onLog}
+			stderr := &lineWriter{stream: devcontainer.Stderr, onLog: onLog}
+			cmd := devcontainer.ExecContext(ctx, devcontainer.ExecConfig{
+				DockerPath:      containerRuntime.DockerPath,
+				DockerHost:      containerRuntime.Socket,
+				WorkspaceFolder: wspath,
+			}, "sh", "-c", c)
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+
+			err := cmd.Run()
+			stdout.flush()
+			stderr.flush()
+			if err != nil {
+				return fmt.Errorf("error post-start command `%s` of plugin `%s`: %w", c, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// describe a failure of post-start commands run under ctx bounded by timeout
+func postStartError(ctx context.Context, timeout time.Duration, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("error post-start commands timed out after %s. the container is kept running", timeout)
+	case ctx.Err() != nil:
+		return errors.New("post-start commands canceled. the container is kept running")
+	}
+	return err
+}
+
+// io.Writer passing each line to onLog
+type lineWriter struct {
+	stream devcontainer.LogStream
+	onLog  func(stream devcontainer.LogStream, line string)
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLog(w.stream, strings.TrimRight(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// pass the last line without newline
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.onLog(w.stream, strings.TrimRight(string(w.buf), "\r"))
+		w.buf = nil
+	}
+}
@@ -3,7 +3,9 @@ package main
 type projectsJson map[string]projectsJsonProject
 
 type projectsJsonProject struct {
-	Path       string
+	Path string
+	// mounts added to every workspace of the project
+	Mounts     []mount `json:",omitempty"`
 	Workspaces map[string]projectsJsonWorkspace
 }
 
@@ -19,8 +21,19 @@ type projectsJsonWorkspace struct {
 	RemoteWorkspaceFolder string
 
 	IPAddress string
+	// container port -> `host:port` published to the host (Runtime.Network "published")
+	PublishedPorts map[int]string `json:",omitempty"`
+
+	// plugins selected at the last launch. used again by rebuild
+	Plugins []string `json:",omitempty"`
 
 	OpenLinks map[string]link
+
+	// visibility of ports set by users. ports not in the map are private
+	PortVisibility map[int]portVisibility
+
+	// worktree directory (Path) does not exist anymore
+	WorktreeMissing bool
 }
 
 type workspaceState string
@@ -29,4 +42,11 @@ const (
 	stateBeforeStart workspaceState = "beforeStart"
 	stateRunning     workspaceState = "running"
 	stateStopped     workspaceState = "stopped"
+	stateStarting    workspaceState = "starting"
+	stateFailed      workspaceState = "failed"
 )
+
+// key of in-memory state per workspace
+func workspaceKey(pjname string, wsname string) string {
+	return pjname + "/" + wsname
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	probeHTTP = "http"
+	probeTCP  = "tcp"
+)
+
+// how long one probe waits for the port
+const probeTimeout = 2 * time.Second
+
+type linkReadiness string
+
+const (
+	// the probe succeeded
+	readinessReady linkReadiness = "ready"
+	// the probe failed. the port is not answering yet
+	readinessNotReady linkReadiness = "notReady"
+	// the link has no probe
+	readinessUnknown linkReadiness = "unknown"
+)
+
+func (p probe) validate() error {
+	switch p.Type {
+	case "", probeHTTP, probeTCP:
+		return nil
+	default:
+		return fmt.Errorf("invalid Probe.Type `%s` (http or tcp)", p.Type)
+	}
+}
+
+// client of HTTP probes. redirects (like to a login page) mean the server is answering
+var probeClient = &http.Client{
+	Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// check the port of l answers.
+// HTTP probes are ready with any response other than 5xx.
+func probeLink(ctx context.Context, ws projectsJsonWorkspace, l link) linkReadiness {
+	if l.Probe == nil {
+		return readinessUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	addr, err := ws.portAddress(l.Port)
+	if err != nil {
+		return readinessNotReady
+	}
+
+	if l.Probe.Type == probeTCP {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return readinessNotReady
+		}
+		conn.Close()
+		return readinessReady
+	}
+
+	p := l.Probe.Path
+	if p == "" {
+		p = l.Path
+	}
+	scheme := "http"
+	if l.Protocol == "https" {
+		scheme = "https"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/%s", scheme, addr, strings.TrimPrefix(p, "/")), nil)
+	if err != nil {
+		return readinessNotReady
+	}
+	res, err := probeClient.Do(req)
+	if err != nil {
+		return readinessNotReady
+	}
+	res.Body.Close()
+	if res.StatusCode >= 500 {
+		return readinessNotReady
+	}
+	return readinessReady
+}
+
+// probe all links of ws at the same time
+func probeLinks(ctx context.Context, ws projectsJsonWorkspace) map[string]linkReadiness {
+	r := make(map[string]linkReadiness)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, l := range ws.OpenLinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := probeLink(ctx, ws, l)
+			mu.Lock()
+			r[name] = s
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return r
+}
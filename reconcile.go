@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"time"
+)
+
+const defaultReconcileInterval = time.Minute
+
+// correct the recorded workspace states with the real containers.
+// projects.json can be stale after devco crashed or the machine rebooted.
+//
+// on startup, workspaces left in stateStarting are marked as failed
+// because the launch that was running is gone.
+func reconcileWorkspaces(st projectStore, startup bool) error {
+	js, err := st.Load()
+	if err != nil {
+		return err
+	}
+
+	for pn, p := range js {
+		for wn, ws := range p.Workspaces {
+			next, changed := reconcileWorkspace(ws, startup)
+			if !changed {
+				continue
+			}
+
+			_, err := updateWorkspace(st, pn, wn, func(cur *projectsJsonWorkspace) error {
+				// changed by someone else while inspecting
+				if cur.State != ws.State || cur.ContainerId != ws.ContainerId {
+					return errReconcileSkipped
+				}
+				*cur = next
+				return nil
+			})
+			if errors.Is(err, errReconcileSkipped) {
+				continue
+			}
+			if err != nil {
+				log.Printf("error reconcile workspace `%s` in project `%s`: %s", wn, pn, err)
+				continue
+			}
+
+			if ws.State != next.State {
+				log.Printf("reconcile: workspace `%s` in project `%s`: %s -> %s", wn, pn, ws.State, next.State)
+			}
+			if !ws.WorktreeMissing && next.WorktreeMissing {
+				log.Printf("reconcile: worktree of workspace `%s` in project `%s` is missing (%s)", wn, pn, ws.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+var errReconcileSkipped = errors.New("reconcile skipped")
+
+func reconcileWorkspace(ws projectsJsonWorkspace, startup bool) (projectsJsonWorkspace, bool) {
+	next := ws
+	next.WorktreeMissing = !exist(ws.Path)
+
+	switch ws.State {
+	case stateStarting:
+		if startup {
+			next.State = stateFailed
+		}
+	case stateRunning, stateStopped:
+		if ws.ContainerId == "" {
+			next.State = stateBeforeStart
+			next.IPAddress = ""
+			next.PublishedPorts = nil
+			break
+		}
+
+		info, err := inspectContainer(ws.ContainerId)
+		if errors.Is(err, errContainerNotFound) {
+			next.State = stateBeforeStart
+			next.ContainerId = ""
+			next.ComposeProjectName = ""
+			next.IPAddress = ""
+			next.PublishedPorts = nil
+			break
+		}
+		if err != nil {
+			log.Printf("error inspect container `%s`: %s", ws.ContainerId, err)
+			break
+		}
+
+		if info.State.Running {
+			next.State = stateRunning
+			if addr, err := addressOf(info); err == nil {
+				next.IPAddress = addr.IPAddress
+				next.PublishedPorts = addr.PublishedPorts
+			}
+		} else {
+			next.State = stateStopped
+			next.IPAddress = ""
+			next.PublishedPorts = nil
+		}
+	}
+
+	changed := next.State != ws.State ||
+		next.ContainerId != ws.ContainerId ||
+		next.ComposeProjectName != ws.ComposeProjectName ||
+		next.IPAddress != ws.IPAddress ||
+		!maps.Equal(next.PublishedPorts, ws.PublishedPorts) ||
+		next.WorktreeMissing != ws.WorktreeMissing
+	return next, changed
+}
+
+// run reconcileWorkspaces every interval until ctx is done
+func reconcileLoop(ctx context.Context, st projectStore, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := reconcileWorkspaces(st, false); err != nil {
+				log.Printf("error reconcile workspaces: %s", err)
+			}
+		}
+	}
+}
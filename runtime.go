@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/0x5341/devco/docker"
+)
+
+const (
+	runtimeDocker = "docker"
+	runtimePodman = "podman"
+)
+
+const (
+	// connect to the IP address of the container
+	networkIP = "ip"
+	// connect to ports published to the host.
+	// for rootless podman, where container IPs are not reachable from the host
+	networkPublished = "published"
+)
+
+// container runtime of devco. set by setupRuntime
+var containerRuntime = runtimeConfig{Type: runtimeDocker, DockerPath: "docker", Network: networkIP}
+
+// client of the Engine API of containerRuntime. set by setupRuntime
+var engine *docker.Client
+
+// fill defaults of c.
+// podman uses the podman CLI and the socket of `podman system service`.
+func (c runtimeConfig) resolve() (runtimeConfig, error) {
+	r := c
+	rootless := os.Geteuid() != 0
+
+	switch r.Type {
+	case "", runtimeDocker:
+		r.Type = runtimeDocker
+		if r.DockerPath == "" {
+			r.DockerPath = "docker"
+		}
+	case runtimePodman:
+		if r.DockerPath == "" {
+			r.DockerPath = "podman"
+		}
+		if r.Socket == "" && os.Getenv("DOCKER_HOST") == "" {
+			if rootless && os.Getenv("XDG_RUNTIME_DIR") != "" {
+				r.Socket = "unix://" + path.Join(os.Getenv("XDG_RUNTIME_DIR"), "podman/podman.sock")
+			} else {
+				r.Socket = "unix:///run/podman/podman.sock"
+			}
+		}
+	default:
+		return runtimeConfig{}, fmt.Errorf("invalid Runtime.Type `%s` (docker or podman)", c.Type)
+	}
+
+	switch r.Network {
+	case "":
+		r.Network = networkIP
+		if r.Type == runtimePodman && rootless {
+			r.Network = networkPublished
+		}
+	case networkIP, networkPublished:
+	default:
+		return runtimeConfig{}, fmt.Errorf("invalid Runtime.Network `%s` (ip or published)", c.Network)
+	}
+
+	return r, nil
+}
+
+func setupRuntime(c runtimeConfig) error {
+	r, err := c.resolve()
+	if err != nil {
+		return err
+	}
+	cli, err := docker.NewClient(r.Socket)
+	if err != nil {
+		return err
+	}
+
+	containerRuntime = r
+	engine = cli
+	return nil
+}
+
+func dockerClient() (*docker.Client, error) {
+	if engine == nil {
+		return nil, errors.New("container runtime is not set up")
+	}
+	return engine, nil
+}
+
+// addresses to reach the container from the host
+type containerAddress struct {
+	// IP of the container. empty on networkPublished
+	IPAddress string
+	// container port -> `host:port` published to the host
+	PublishedPorts map[int]string
+}
+
+// find addresses of the container for containerRuntime.Network
+func lookupAddress(cid string) (containerAddress, error) {
+	info, err := inspectContainer(cid)
+	if err != nil {
+		return containerAddress{}, err
+	}
+	return addressOf(info)
+}
+
+func addressOf(info docker.ContainerJSON) (containerAddress, error) {
+	if containerRuntime.Network == networkPublished {
+		return containerAddress{PublishedPorts: publishedPorts(info)}, nil
+	}
+
+	ip, err := info.IPAddress()
+	if err != nil {
+		return containerAddress{}, err
+	}
+	return containerAddress{IPAddress: ip}, nil
+}
+
+// TCP ports published to the host. wildcard host IPs are replaced by loopback.
+func publishedPorts(info docker.ContainerJSON) map[int]string {
+	ports := make(map[int]string)
+	for key, bindings := range info.NetworkSettings.Ports {
+		p, proto, _ := strings.Cut(key, "/")
+		if proto != "" && proto != "tcp" {
+			continue
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+
+		for _, b := range bindings {
+			if b.HostPort == "" {
+				continue
+			}
+			host := b.HostIp
+			switch host {
+			case "", "0.0.0.0":
+				host = "127.0.0.1"
+			case "::":
+				host = "::1"
+			}
+			ports[port] = net.JoinHostPort(host, b.HostPort)
+			break
+		}
+	}
+	return ports
+}
+
+// `host:port` to connect to port of the workspace container
+func (ws projectsJsonWorkspace) portAddress(port int) (string, error) {
+	if containerRuntime.Network == networkPublished {
+		if addr, ok := ws.PublishedPorts[port]; ok {
+			return addr, nil
+		}
+		return "", statusErrorf(http.StatusBadGateway, "error port %d is not published to the host (publish it by `appPort` or `runArgs` in devcontainer.json)", port)
+	}
+
+	if ws.IPAddress == "" {
+		return "", statusErrorf(http.StatusBadGateway, "error IPAddress of the container is unknown")
+	}
+	return net.JoinHostPort(ws.IPAddress, strconv.Itoa(port)), nil
+}
@@ -10,24 +10,124 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+
+	"github.com/0x5341/devco/devcontainer"
 )
 
 func serve(addr string, datadir string, conf config) {
-	serveAPI(datadir, conf)
+	lock, err := lockDatadir(datadir)
+	if err != nil {
+		log.Fatalf("failed to lock datadir: %s", err)
+	}
+	defer lock.Close()
+
+	err = setupRuntime(conf.Runtime)
+	if err != nil {
+		log.Fatalf("failed to setup container runtime: %s", err)
+	}
+	if err := devcontainer.DetectDevcontainer(); err != nil {
+		log.Printf("warning: %s. run `devco doctor` to check the environment", err)
+	}
+
+	st, err := openProjectStore(datadir, conf.Store)
+	if err != nil {
+		log.Fatalf("failed to open store: %s", err)
+	}
+	defer st.Close()
+
+	interval, err := conf.reconcileInterval()
+	if err != nil {
+		log.Fatalf("failed to load config: %s", err)
+	}
+
+	scanInterval, err := conf.portScanInterval()
+	if err != nil {
+		log.Fatalf("failed to load config: %s", err)
+	}
+
+	policy, err := conf.shutdownPolicy()
+	if err != nil {
+		log.Fatalf("failed to load config: %s", err)
+	}
+
+	timeouts, err := conf.timeouts()
+	if err != nil {
+		log.Fatalf("failed to load config: %s", err)
+	}
+
+	if err := conf.validatePlugins(); err != nil {
+		log.Fatalf("failed to load config: %s", err)
+	}
+
+	err = reconcileWorkspaces(st, true)
+	if err != nil {
+		log.Printf("failed to reconcile workspaces: %s", err)
+	}
+
+	auth, err := newAuthManager(datadir, conf.Auth, conf.PortDomain)
+	if err != nil {
+		log.Fatalf("failed to setup auth: %s", err)
+	}
+	if auth == nil {
+		log.Printf("authentication is disabled. set `Auth.Token` in config to enable it")
+	}
+
+	if conf.PortDomain != "" {
+		conf.TLS.Hosts = append(conf.TLS.Hosts, conf.PortDomain, "*."+conf.PortDomain)
+	}
+	tlsConf, err := loadTLSConfig(datadir, conf.TLS)
+	if err != nil {
+		log.Fatalf("failed to setup TLS: %s", err)
+	}
+
+	shares, err := newPortShares(datadir, st)
+	if err != nil {
+		log.Fatalf("failed to setup share links: %s", err)
+	}
+
+	var gateway *sshGateway
+	keys := newAuthorizedKeys(datadir)
+	if conf.SSH.Address != "" {
+		gateway, err = newSSHGateway(datadir, st, keys)
+		if err != nil {
+			log.Fatalf("failed to setup SSH gateway: %s", err)
+		}
+	}
+
+	scanner := newPortScanner()
+	jobs := newJobStore()
+	serveAPI(datadir, st, conf, auth, timeouts, jobs, scanner, shares)
+	serveSSHAPI(keys, gateway)
+	serveAuthAPI(auth)
+	serveTLSAPI(datadir, conf.TLS)
 	serveUI()
 
 	sig, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
+	go reconcileLoop(sig, st, interval)
+	go scanner.loop(sig, st, scanInterval)
+	if gateway != nil {
+		go func() {
+			if err := gateway.serve(sig, conf.SSH.Address); err != nil {
+				log.Fatalf("SSH gateway error: %s", err)
+			}
+		}()
+	}
+
 	server := &http.Server{
-		Addr:    addr,
-		Handler: nil,
+		Addr:      addr,
+		Handler:   portHostMiddleware(conf.PortDomain, st, auth, shares, scanner, conf.ProxyAllPorts, authMiddleware(auth, shares, csrfMiddleware(http.DefaultServeMux))),
+		TLSConfig: tlsConf,
 	}
 
 	ch := make(chan struct{})
 
 	server.RegisterOnShutdown(func() {
-		js, err := loadProjectsJson(datadir)
+		// running launches are aborted and cleaned up
+		jobs.shutdown()
+
+		js, err := st.Load()
 		if err != nil {
 			log.Printf("failed to shutdown: %s", err)
 			return
@@ -38,33 +138,45 @@ func serve(addr string, datadir string, conf config) {
 				if w.State != stateRunning {
 					continue
 				}
-				err := downContainer(&js, pn, wn)
+
+				switch policy {
+				case shutdownDown:
+					ctx, cancel := withTimeout(context.Background(), timeouts.Down)
+					err = downContainer(ctx, st, pn, wn)
+					cancel()
+				case shutdownStop:
+					ctx, cancel := withTimeout(context.Background(), timeouts.Stop)
+					err = stopContainer(ctx, st, pn, wn)
+					cancel()
+				case shutdownLeave:
+					err = nil
+				}
 				if err != nil {
-					log.Printf("failed shutdown (fail to down container on workspace `%s` in project `%s`)", wn, pn)
+					log.Printf("failed shutdown (fail to %s container on workspace `%s` in project `%s`): %s", policy, wn, pn, err)
 					log.Printf("shutdown continue...")
 				}
 			}
 		}
 
-		_, err = writeProjectsJson(datadir, js)
-		if err != nil {
-			log.Printf("failed to shutdown: %s", err)
-			return
-		}
-
-		log.Printf("`downContainer` shutdown finished")
+		log.Printf("`%s` shutdown finished", policy)
 		ch <- struct{}{}
 	})
 
 	go func() {
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if tlsConf != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("serve error: %s", err)
 		}
 	}()
 	<-sig.Done()
 
 	log.Printf("start graceful shutdown...")
-	err := server.Shutdown(context.Background())
+	err = server.Shutdown(context.Background())
 	if err != nil {
 		log.Fatalf("failed shutdown: %s", err)
 	}
@@ -88,12 +200,18 @@ func serveUI() {
 	})
 }
 
-func serveAPI(datadir string, conf config) {
+func serveAPI(datadir string, st projectStore, conf config, auth *authManager, timeouts operationTimeouts, jobs *jobStore, scanner *portScanner, shares *portShares) {
 	serveConfigAPI(conf)
-	serveProjectAPI(datadir)
-	serveWorkspaceAPI(datadir)
-	serveContainerAPI(datadir, conf)
-	servePortAccessAPI(datadir)
+	serveHealthAPI(datadir, st)
+	serveProjectAPI(st)
+	logs := newLogStore()
+	serveWorkspaceAPI(datadir, st, timeouts, jobs)
+	serveContainerAPI(st, conf, timeouts, logs, jobs)
+	serveLogAPI(logs)
+	serveJobAPI(jobs)
+	servePortAPI(st, conf, auth, scanner, shares)
+	serveTerminalAPI(st)
+	serveTunnelAPI(st, scanner, conf.ProxyAllPorts)
 }
 
 func errPrint(w http.ResponseWriter, code int, fmtstr string, v ...any) {
@@ -10,6 +10,10 @@ func serveConfigAPI(conf config) {
 }
 
 func serveGetConfigAPI(conf config) {
+	// never expose secrets. conf is our own copy, so clear it once here
+	// instead of writing it from concurrent requests
+	conf.Auth.Token = ""
+
 	http.HandleFunc("GET /api/config", func(w http.ResponseWriter, r *http.Request) {
 		b, err := json.Marshal(conf)
 		if err != nil {
@@ -1,23 +1,36 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"maps"
 	"net/http"
-	"os/exec"
+	"time"
 
 	"github.com/0x5341/devco/devcontainer"
+	"github.com/0x5341/devco/docker"
 )
 
-func serveContainerAPI(datadir string, conf config) {
-	serveLaunchContainerAPI(datadir, conf)
-	serveDownContainerAPI(datadir)
-	serveGetOpenLinksAPI(datadir)
+const (
+	defaultLaunchTimeout    = 30 * time.Minute
+	defaultOperationTimeout = 2 * time.Minute
+	defaultPostStartTimeout = 10 * time.Minute
+)
+
+func serveContainerAPI(st projectStore, conf config, timeouts operationTimeouts, logs *logStore, jobs *jobStore) {
+	serveLaunchContainerAPI(st, conf, timeouts, logs, jobs)
+	serveRebuildContainerAPI(st, conf, timeouts, logs, jobs)
+	serveCancelLaunchAPI(jobs)
+	serveDownContainerAPI(st, timeouts, jobs)
+	serveStopContainerAPI(st, timeouts, jobs)
+	serveStartContainerAPI(st, conf, timeouts, logs, jobs)
+	serveGetOpenLinksAPI(st)
 }
 
-func serveLaunchContainerAPI(datadir string, cf config) {
+func serveLaunchContainerAPI(st projectStore, cf config, timeouts operationTimeouts, logs *logStore, jobs *jobStore) {
 	type conf struct {
 		ProjectName   string
 		WorkspaceName string
@@ -31,7 +44,7 @@ func serveLaunchContainerAPI(datadir string, cf config) {
 			return
 		}
 
-		js, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
@@ -46,67 +59,360 @@ func serveLaunchContainerAPI(datadir string, cf config) {
 			return
 		}
 
-		if js[c.ProjectName].Workspaces[c.WorkspaceName].State == stateRunning {
+		switch js[c.ProjectName].Workspaces[c.WorkspaceName].State {
+		case stateRunning:
 			errPrint(w, http.StatusBadRequest, "error container already launched in workspace `%s`", c.WorkspaceName)
 			return
+		case stateStarting:
+			errPrint(w, http.StatusBadRequest, "error container is starting in workspace `%s`", c.WorkspaceName)
+			return
 		}
 
-		for _, s := range c.Plugins {
-			if _, ok := cf.Plugins[s]; !ok {
-				errPrint(w, http.StatusBadRequest, "error plugin `%s` is not exist", s)
-				return
-			}
+		if !exist(js[c.ProjectName].Workspaces[c.WorkspaceName].Path) {
+			errPrint(w, http.StatusBadRequest, "error worktree of workspace `%s` is missing", c.WorkspaceName)
+			return
+		}
+
+		plugins, ok := jsonHelper[mergedPlugins](w)(mergePlugins(cf, c.Plugins))
+		if !ok {
+			return
+		}
+
+		mounts, ok := jsonHelper[[]devcontainer.MountConfig](w)(workspaceMounts(cf, js[c.ProjectName], c.ProjectName, c.WorkspaceName, plugins.Names))
+		if !ok {
+			return
+		}
+
+		pjname := c.ProjectName
+		wsname := c.WorkspaceName
+		wspath := js[pjname].Workspaces[wsname].Path
+		acceptJob(w, jobs, jobLaunch, pjname, wsname, func(ctx context.Context, progress func(string)) (any, error) {
+			return launchWorkspace(ctx, st, cf, timeouts, logs, pjname, wsname, wspath, plugins, mounts, nil, progress)
+		})
+	})
+}
+
+// remove the container and create it again from fresh images, with the plugins of the last launch.
+// used after Dockerfile or devcontainer.json is changed.
+func serveRebuildContainerAPI(st projectStore, cf config, timeouts operationTimeouts, logs *logStore, jobs *jobStore) {
+	type conf struct {
+		ProjectName   string
+		WorkspaceName string
+		// build images without docker build cache. default: true
+		NoCache *bool
+		// fail if the container of the workspace is gone. default: false
+		ExpectExisting bool
+	}
+	http.HandleFunc("POST /api/workspace/rebuild", func(w http.ResponseWriter, r *http.Request) {
+		var c conf
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
+			return
+		}
+
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
+		if !ok {
+			return
+		}
+
+		if _, ok = js[c.ProjectName]; !ok {
+			errPrint(w, http.StatusNotFound, "error project `%s` not exists", c.ProjectName)
+			return
+		}
+
+		ws, ok := js[c.ProjectName].Workspaces[c.WorkspaceName]
+		if !ok {
+			errPrint(w, http.StatusNotFound, "error workspace `%s` not exists in project `%s`", c.WorkspaceName, c.ProjectName)
+			return
+		}
+
+		if ws.State == stateStarting {
+			errPrint(w, http.StatusBadRequest, "error container is starting in workspace `%s`", c.WorkspaceName)
+			return
+		}
+
+		if ws.State == stateBeforeStart {
+			errPrint(w, http.StatusBadRequest, "error container is not launched in workspace `%s`", c.WorkspaceName)
+			return
+		}
+
+		if !exist(ws.Path) {
+			errPrint(w, http.StatusBadRequest, "error worktree of workspace `%s` is missing", c.WorkspaceName)
+			return
+		}
+
+		rebuild := &rebuildOptions{NoCache: true, ExpectExisting: c.ExpectExisting}
+		if c.NoCache != nil {
+			rebuild.NoCache = *c.NoCache
+		}
+
+		// plugins may be removed or changed in config after the last launch
+		plugins, ok := jsonHelper[mergedPlugins](w)(mergePlugins(cf, ws.Plugins))
+		if !ok {
+			return
 		}
 
-		features := make(map[string]map[string]any)
-		for _, name := range c.Plugins {
-			maps.Copy(features, cf.Plugins[name].Features)
+		mounts, ok := jsonHelper[[]devcontainer.MountConfig](w)(workspaceMounts(cf, js[c.ProjectName], c.ProjectName, c.WorkspaceName, plugins.Names))
+		if !ok {
+			return
 		}
 
-		res, err := devcontainer.Up(devcontainer.UpConfig{
-			WorkspaceFolder:    js[c.ProjectName].Workspaces[c.WorkspaceName].Path,
-			AdditionalFeatures: features,
+		pjname := c.ProjectName
+		wsname := c.WorkspaceName
+		acceptJob(w, jobs, jobRebuild, pjname, wsname, func(ctx context.Context, progress func(string)) (any, error) {
+			return launchWorkspace(ctx, st, cf, timeouts, logs, pjname, wsname, ws.Path, plugins, mounts, rebuild, progress)
 		})
+	})
+}
+
+// abort running launch (or rebuild) of the workspace.
+// the job finishes as canceled after removing what was created, and the workspace goes back to beforeStart.
+func serveCancelLaunchAPI(jobs *jobStore) {
+	type conf struct {
+		ProjectName   string
+		WorkspaceName string
+	}
+	http.HandleFunc("POST /api/workspace/launch/cancel", func(w http.ResponseWriter, r *http.Request) {
+		var c conf
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
+			return
+		}
+
+		j, ok := jobs.cancel(c.ProjectName, c.WorkspaceName, jobLaunch, jobRebuild)
+		if !ok {
+			errPrint(w, http.StatusNotFound, "error no launch is running on workspace `%s` in project `%s`", c.WorkspaceName, c.ProjectName)
+			return
+		}
+
+		b, err := json.Marshal(struct{ JobId string }{j.Id})
 		if err != nil {
-			errPrint(w, http.StatusInternalServerError, "error launch container: %s", err)
+			errPrint(w, http.StatusInternalServerError, "error encode job: %s", err)
 			return
 		}
 
-		ws := js[c.ProjectName].Workspaces[c.WorkspaceName]
+		w.Header().Set("Location", "/api/jobs/"+j.Id)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(b)
+	})
+}
+
+// how [launchWorkspace] rebuilds the container
+type rebuildOptions struct {
+	// build images without docker build cache
+	NoCache bool
+	// fail if the container of the workspace does not exist
+	ExpectExisting bool
+}
+
+// run `devcontainer up` for the workspace and record the container.
+// rebuild (if not nil) removes the existing container and creates new one.
+// when ctx is canceled or timeouts.Launch is exceeded, containers of the workspace are removed.
+func launchWorkspace(ctx context.Context, st projectStore, cf config, timeouts operationTimeouts, logs *logStore, pjname string, wsname string, wspath string, plugins mergedPlugins, mounts []devcontainer.MountConfig, rebuild *rebuildOptions, progress func(string)) (_ any, err error) {
+	jobCtx := ctx
+	ctx, cancel := withTimeout(ctx, timeouts.Launch)
+	defer cancel()
+
+	// output of `devcontainer up` and post-start commands
+	l := logs.start(pjname, wsname)
+	defer func() { l.finish(err) }()
+
+	_, err = updateWorkspace(st, pjname, wsname, func(ws *projectsJsonWorkspace) error {
+		ws.State = stateStarting
+		ws.Plugins = plugins.Names
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// containers created so far are recorded, so they can be removed by down or delete
+	fail := func(err error, res devcontainer.UpResult) (any, error) {
+		_, uerr := updateWorkspace(st, pjname, wsname, func(ws *projectsJsonWorkspace) error {
+			ws.State = stateFailed
+			ws.ContainerId = res.ContainerId
+			ws.ComposeProjectName = res.ComposeProjectName
+			return nil
+		})
+		if uerr != nil {
+			log.Printf("error mark workspace `%s` in project `%s` as failed: %s", wsname, pjname, uerr)
+		}
+		return nil, err
+	}
+
+	// canceled or timed out. remove what was created so far and go back to beforeStart
+	abort := func(down devcontainer.DownConfig) (any, error) {
+		progress("clean up")
+		dctx, dcancel := withTimeout(context.Background(), timeouts.Down)
+		defer dcancel()
+
+		down.DockerHost = containerRuntime.Socket
+		down.WorkspaceFolder = wspath
+		err := devcontainer.DownContext(dctx, down)
+		if err != nil && !errors.Is(err, docker.ErrNotFound) {
+			return fail(fmt.Errorf("error clean up aborted launch: %s", err), devcontainer.UpResult{ContainerId: down.ContainerId, ComposeProjectName: down.ComposeProjectName})
+		}
+
+		_, err = updateWorkspace(st, pjname, wsname, func(ws *projectsJsonWorkspace) error {
+			ws.State = stateBeforeStart
+			ws.ContainerId = ""
+			ws.ComposeProjectName = ""
+			ws.IPAddress = ""
+			ws.PublishedPorts = nil
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("error launch timed out after %s", timeouts.Launch)
+		}
+		return nil, errors.New("launch canceled")
+	}
+
+	if rebuild != nil {
+		progress("rebuild container")
+	} else {
+		progress("launch container")
+	}
+	up := devcontainer.UpConfig{
+		DockerPath:         containerRuntime.DockerPath,
+		DockerComposePath:  containerRuntime.DockerComposePath,
+		DockerHost:         containerRuntime.Socket,
+		WorkspaceFolder:    wspath,
+		AdditionalFeatures: plugins.Features,
+		AdditionalMounts:   mounts,
+		OnLog: func(stream devcontainer.LogStream, line string) {
+			l.append(stream, line)
+			progress(line)
+		},
+	}
+	if rebuild != nil {
+		up.RemoveExistingContainer = true
+		up.BuildNoCache = rebuild.NoCache
+		up.ExpectExistingContainer = rebuild.ExpectExisting
+	}
+	res, err := devcontainer.UpContext(ctx, up)
+	if ctx.Err() != nil {
+		return abort(devcontainer.DownConfig{ContainerId: res.ContainerId, ComposeProjectName: res.ComposeProjectName})
+	}
+	if err != nil {
+		return fail(fmt.Errorf("error launch container: %w", err), res)
+	}
+
+	progress("get address")
+	addr, err := lookupAddress(res.ContainerId)
+	if err != nil {
+		return fail(fmt.Errorf("error get address of container: %s", err), res)
+	}
+
+	// ports declared in devcontainer.json. launch succeeds without them
+	progress("read configuration")
+	var declared map[string]link
+	dcconf, err := devcontainer.ReadConfigurationContext(ctx, devcontainer.ReadConfigurationConfig{
+		DockerPath:        containerRuntime.DockerPath,
+		DockerComposePath: containerRuntime.DockerComposePath,
+		DockerHost:        containerRuntime.Socket,
+		WorkspaceFolder:   wspath,
+	})
+	if err != nil {
+		log.Printf("error read configuration of workspace `%s` in project `%s`: %s", wsname, pjname, err)
+	} else {
+		declared = declaredLinks(dcconf)
+	}
+
+	if ctx.Err() != nil {
+		return abort(devcontainer.DownConfig{ContainerId: res.ContainerId, ComposeProjectName: res.ComposeProjectName})
+	}
+
+	var result projectsJsonWorkspace
+	_, err = updateWorkspace(st, pjname, wsname, func(ws *projectsJsonWorkspace) error {
 		ws.State = stateRunning
 		ws.ComposeProjectName = res.ComposeProjectName
 		ws.ContainerId = res.ContainerId
 		ws.RemoteUser = res.RemoteUser
 		ws.RemoteWorkspaceFolder = res.RemoteWorkspaceFolder
+		ws.IPAddress = addr.IPAddress
+		ws.PublishedPorts = addr.PublishedPorts
 
-		ws.OpenLinks = make(map[string]link)
-		for _, name := range c.Plugins {
-			maps.Copy(ws.OpenLinks, cf.Plugins[name].Links)
-		}
+		ws.OpenLinks = maps.Clone(plugins.Links)
+		mergeDeclaredLinks(ws.OpenLinks, declared)
+
+		result = *ws
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the container is running even if a command fails, times out or is canceled.
+	// the error is reported by the job and the launch log, and the container is kept
+	pctx, pcancel := withTimeout(jobCtx, timeouts.PostStart)
+	defer pcancel()
+	err = runPostStartCommands(pctx, cf, plugins.Names, wspath, l.append, progress)
+	if err != nil {
+		return nil, postStartError(pctx, timeouts.PostStart, err)
+	}
 
-		addr, err := getIPAddress(res.ContainerId)
+	return result, nil
+}
+
+func serveDownContainerAPI(st projectStore, timeouts operationTimeouts, jobs *jobStore) {
+	type conf struct {
+		ProjectName   string
+		WorkspaceName string
+	}
+	http.HandleFunc("POST /api/workspace/down", func(w http.ResponseWriter, r *http.Request) {
+		var c conf
+		err := json.NewDecoder(r.Body).Decode(&c)
 		if err != nil {
-			errPrint(w, http.StatusInternalServerError, "error get IPAddress: %s", err)
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
 			return
 		}
-		ws.IPAddress = addr
-		js[c.ProjectName].Workspaces[c.WorkspaceName] = ws
 
-		_, ok = jsonHelper[struct{}](w)(writeProjectsJson(datadir, js))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		if _, ok := js[c.ProjectName]; !ok {
+			errPrint(w, http.StatusNotFound, "error project `%s` not exists", c.ProjectName)
+			return
+		}
+
+		if _, ok := js[c.ProjectName].Workspaces[c.WorkspaceName]; !ok {
+			errPrint(w, http.StatusNotFound, "error workspace `%s` not exists in project `%s`", c.WorkspaceName, c.ProjectName)
+			return
+		}
+
+		if state := js[c.ProjectName].Workspaces[c.WorkspaceName].State; state != stateRunning && state != stateStopped && state != stateFailed {
+			errPrint(w, http.StatusBadRequest, "error container already downed in workspace `%s`", c.WorkspaceName)
+			return
+		}
+
+		acceptJob(w, jobs, jobDown, c.ProjectName, c.WorkspaceName, func(ctx context.Context, progress func(string)) (any, error) {
+			ctx, cancel := withTimeout(ctx, timeouts.Down)
+			defer cancel()
+
+			progress("down container")
+			err := downContainer(ctx, st, c.ProjectName, c.WorkspaceName)
+			if err != nil {
+				return nil, fmt.Errorf("error during down container: %s", err)
+			}
+			return nil, nil
+		})
 	})
 }
 
-func serveDownContainerAPI(datadir string) {
+func serveStopContainerAPI(st projectStore, timeouts operationTimeouts, jobs *jobStore) {
 	type conf struct {
 		ProjectName   string
 		WorkspaceName string
 	}
-	http.HandleFunc("POST /api/workspace/down", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("POST /api/workspace/stop", func(w http.ResponseWriter, r *http.Request) {
 		var c conf
 		err := json.NewDecoder(r.Body).Decode(&c)
 		if err != nil {
@@ -114,27 +420,124 @@ func serveDownContainerAPI(datadir string) {
 			return
 		}
 
-		js, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
 
-		err = downContainer(&js, c.ProjectName, c.WorkspaceName)
+		if _, ok := js[c.ProjectName]; !ok {
+			errPrint(w, http.StatusNotFound, "error project `%s` not exists", c.ProjectName)
+			return
+		}
+
+		if _, ok := js[c.ProjectName].Workspaces[c.WorkspaceName]; !ok {
+			errPrint(w, http.StatusNotFound, "error workspace `%s` not exists in project `%s`", c.WorkspaceName, c.ProjectName)
+			return
+		}
+
+		if js[c.ProjectName].Workspaces[c.WorkspaceName].State != stateRunning {
+			errPrint(w, http.StatusBadRequest, "error container is not running in workspace `%s`", c.WorkspaceName)
+			return
+		}
+
+		acceptJob(w, jobs, jobStop, c.ProjectName, c.WorkspaceName, func(ctx context.Context, progress func(string)) (any, error) {
+			ctx, cancel := withTimeout(ctx, timeouts.Stop)
+			defer cancel()
+
+			progress("stop container")
+			err := stopContainer(ctx, st, c.ProjectName, c.WorkspaceName)
+			if err != nil {
+				return nil, fmt.Errorf("error during stop container: %s", err)
+			}
+			return nil, nil
+		})
+	})
+}
+
+func serveStartContainerAPI(st projectStore, cf config, timeouts operationTimeouts, logs *logStore, jobs *jobStore) {
+	type conf struct {
+		ProjectName   string
+		WorkspaceName string
+	}
+	http.HandleFunc("POST /api/workspace/start", func(w http.ResponseWriter, r *http.Request) {
+		var c conf
+		err := json.NewDecoder(r.Body).Decode(&c)
 		if err != nil {
-			errPrint(w, http.StatusNotFound, "error during down container: %s", err)
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
 			return
 		}
 
-		_, ok = jsonHelper[struct{}](w)(writeProjectsJson(datadir, js))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		if _, ok := js[c.ProjectName]; !ok {
+			errPrint(w, http.StatusNotFound, "error project `%s` not exists", c.ProjectName)
+			return
+		}
+
+		if _, ok := js[c.ProjectName].Workspaces[c.WorkspaceName]; !ok {
+			errPrint(w, http.StatusNotFound, "error workspace `%s` not exists in project `%s`", c.WorkspaceName, c.ProjectName)
+			return
+		}
+
+		if js[c.ProjectName].Workspaces[c.WorkspaceName].State != stateStopped {
+			errPrint(w, http.StatusBadRequest, "error container is not stopped in workspace `%s`", c.WorkspaceName)
+			return
+		}
+
+		acceptJob(w, jobs, jobStart, c.ProjectName, c.WorkspaceName, func(jobCtx context.Context, progress func(string)) (_ any, err error) {
+			ctx, cancel := withTimeout(jobCtx, timeouts.Start)
+			defer cancel()
+
+			// output of lifecycle and post-start commands, read by the same API as launch
+			l := logs.start(c.ProjectName, c.WorkspaceName)
+			defer func() { l.finish(err) }()
+			onLog := func(stream devcontainer.LogStream, line string) {
+				l.append(stream, line)
+				progress(line)
+			}
+
+			progress("start container")
+			err = startContainer(ctx, st, c.ProjectName, c.WorkspaceName)
+			if err != nil {
+				return nil, fmt.Errorf("error during start container: %w", err)
+			}
+
+			// processes started by post-start commands are gone with the stop
+			js, err := st.Load()
+			if err != nil {
+				return nil, err
+			}
+			ws := js[c.ProjectName].Workspaces[c.WorkspaceName]
+			pctx, pcancel := withTimeout(jobCtx, timeouts.PostStart)
+			defer pcancel()
+
+			// postStartCommand and postAttachCommand of devcontainer.json, like `devcontainer up` runs them
+			progress("run lifecycle commands")
+			err = devcontainer.RunUserCommandsContext(pctx, devcontainer.RunUserCommandsConfig{
+				DockerPath:        containerRuntime.DockerPath,
+				DockerComposePath: containerRuntime.DockerComposePath,
+				DockerHost:        containerRuntime.Socket,
+				WorkspaceFolder:   ws.Path,
+				ContainerId:       ws.ContainerId,
+				OnLog:             onLog,
+			})
+			if err != nil {
+				return nil, postStartError(pctx, timeouts.PostStart, err)
+			}
+
+			err = runPostStartCommands(pctx, cf, ws.Plugins, ws.Path, onLog, progress)
+			if err != nil {
+				return nil, postStartError(pctx, timeouts.PostStart, err)
+			}
+			return nil, nil
+		})
 	})
 }
 
-func serveGetOpenLinksAPI(datadir string) {
+func serveGetOpenLinksAPI(st projectStore) {
 	http.HandleFunc("GET /api/workspace/openlink", func(w http.ResponseWriter, r *http.Request) {
 		checkParam := func(name string) bool {
 			if !r.URL.Query().Has(name) {
@@ -153,7 +556,7 @@ func serveGetOpenLinksAPI(datadir string) {
 		pjname := r.URL.Query().Get("pjname")
 		wsname := r.URL.Query().Get("wsname")
 
-		js, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
@@ -168,11 +571,21 @@ func serveGetOpenLinksAPI(datadir string) {
 			return
 		}
 
-		if js[pjname].Workspaces[wsname].State != stateRunning {
+		ws := js[pjname].Workspaces[wsname]
+		if ws.State != stateRunning {
 			errPrint(w, http.StatusBadRequest, "error workspace `%s` is not running", wsname)
+			return
 		}
 
-		links := js[pjname].Workspaces[wsname].OpenLinks
+		type openLink struct {
+			link
+			Readiness linkReadiness
+		}
+		readiness := probeLinks(r.Context(), ws)
+		links := make(map[string]openLink)
+		for name, l := range ws.OpenLinks {
+			links[name] = openLink{link: l, Readiness: readiness[name]}
+		}
 
 		b, err := json.Marshal(links)
 		if err != nil {
@@ -184,58 +597,130 @@ func serveGetOpenLinksAPI(datadir string) {
 	})
 }
 
-func downContainer(js *projectsJson, pjname string, wsname string) error {
-	if _, ok := (*js)[pjname]; !ok {
+// remove the container of the workspace and mark it as not started.
+// the store is not locked while the container is removed.
+func downContainer(ctx context.Context, st projectStore, pjname string, wsname string) error {
+	js, err := st.Load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := js[pjname]; !ok {
+		return fmt.Errorf("project `%s` not exists", pjname)
+	}
+
+	if _, ok := js[pjname].Workspaces[wsname]; !ok {
+		return fmt.Errorf("workspace `%s` not exists in project `%s`", wsname, pjname)
+	}
+
+	if state := js[pjname].Workspaces[wsname].State; state != stateRunning && state != stateStopped && state != stateFailed {
+		return fmt.Errorf("container already downed in workspace `%s`", wsname)
+	}
+
+	// a failed launch may not know its container. it is found by the workspace folder then
+	err = devcontainer.DownContext(ctx, devcontainer.DownConfig{
+		DockerHost:         containerRuntime.Socket,
+		ComposeProjectName: js[pjname].Workspaces[wsname].ComposeProjectName,
+		ContainerId:        js[pjname].Workspaces[wsname].ContainerId,
+		WorkspaceFolder:    js[pjname].Workspaces[wsname].Path,
+	})
+	// already removed by someone else
+	if err != nil && !errors.Is(err, docker.ErrNotFound) {
+		return fmt.Errorf("error remove container: %w", err)
+	}
+
+	_, err = updateWorkspace(st, pjname, wsname, func(ws *projectsJsonWorkspace) error {
+		ws.State = stateBeforeStart
+		ws.ContainerId = ""
+		ws.ComposeProjectName = ""
+		ws.IPAddress = ""
+		ws.PublishedPorts = nil
+		return nil
+	})
+	return err
+}
+
+// stop the container of the workspace without removing it.
+// reconcileWorkspaces adopts it as stateStopped on the next start.
+func stopContainer(ctx context.Context, st projectStore, pjname string, wsname string) error {
+	js, err := st.Load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := js[pjname]; !ok {
 		return fmt.Errorf("project `%s` not exists", pjname)
 	}
 
-	if _, ok := (*js)[pjname].Workspaces[wsname]; !ok {
+	if _, ok := js[pjname].Workspaces[wsname]; !ok {
 		return fmt.Errorf("workspace `%s` not exists in project `%s`", wsname, pjname)
 	}
 
-	if (*js)[pjname].Workspaces[wsname].State != stateRunning {
-		return fmt.Errorf("container already stopped in workspace `%s`", wsname)
+	if js[pjname].Workspaces[wsname].State != stateRunning {
+		return fmt.Errorf("container is not running in workspace `%s`", wsname)
 	}
 
-	err := devcontainer.Down(devcontainer.DownConfig{
-		ComposeProjectName: (*js)[pjname].Workspaces[wsname].ComposeProjectName,
-		ContainerId:        (*js)[pjname].Workspaces[wsname].ContainerId,
+	err = devcontainer.StopContext(ctx, devcontainer.StopConfig{
+		DockerPath:         containerRuntime.DockerPath,
+		DockerComposePath:  containerRuntime.DockerComposePath,
+		DockerHost:         containerRuntime.Socket,
+		ComposeProjectName: js[pjname].Workspaces[wsname].ComposeProjectName,
+		ContainerId:        js[pjname].Workspaces[wsname].ContainerId,
 	})
 	if err != nil {
 		return fmt.Errorf("error stop container: %s", err)
 	}
 
-	ws := (*js)[pjname].Workspaces[wsname]
-	ws.State = stateBeforeStart
-	(*js)[pjname].Workspaces[wsname] = ws
-
-	return nil
+	_, err = updateWorkspace(st, pjname, wsname, func(ws *projectsJsonWorkspace) error {
+		ws.State = stateStopped
+		ws.IPAddress = ""
+		ws.PublishedPorts = nil
+		return nil
+	})
+	return err
 }
 
-func getIPAddress(cid string) (string, error) {
-	b, err := exec.Command("docker", "inspect", cid).Output()
+// start the container stopped by stopContainer. lifecycle commands are not run here.
+// addresses are refreshed because docker may assign other ones.
+func startContainer(ctx context.Context, st projectStore, pjname string, wsname string) error {
+	js, err := st.Load()
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	type info []struct {
-		NetworkSettings struct {
-			Networks map[string]struct {
-				IPAddress string
-			}
-		}
+	if _, ok := js[pjname]; !ok {
+		return fmt.Errorf("project `%s` not exists", pjname)
+	}
+
+	if _, ok := js[pjname].Workspaces[wsname]; !ok {
+		return fmt.Errorf("workspace `%s` not exists in project `%s`", wsname, pjname)
+	}
+
+	if js[pjname].Workspaces[wsname].State != stateStopped {
+		return fmt.Errorf("container is not stopped in workspace `%s`", wsname)
 	}
 
-	var i info
-	err = json.Unmarshal(b, &i)
+	err = devcontainer.StartContext(ctx, devcontainer.StartConfig{
+		DockerPath:         containerRuntime.DockerPath,
+		DockerComposePath:  containerRuntime.DockerComposePath,
+		DockerHost:         containerRuntime.Socket,
+		ComposeProjectName: js[pjname].Workspaces[wsname].ComposeProjectName,
+		ContainerId:        js[pjname].Workspaces[wsname].ContainerId,
+	})
 	if err != nil {
-		return "", err
+		return fmt.Errorf("error start container: %w", err)
 	}
 
-	for _, net := range i[0].NetworkSettings.Networks {
-		addr := net.IPAddress
-		return addr, nil
+	addr, err := lookupAddress(js[pjname].Workspaces[wsname].ContainerId)
+	if err != nil {
+		return fmt.Errorf("error get address of container: %w", err)
 	}
 
-	return "", errors.New("IPAddress not found")
+	_, err = updateWorkspace(st, pjname, wsname, func(ws *projectsJsonWorkspace) error {
+		ws.State = stateRunning
+		ws.IPAddress = addr.IPAddress
+		ws.PublishedPorts = addr.PublishedPorts
+		return nil
+	})
+	return err
 }
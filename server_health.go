@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func serveHealthAPI(datadir string, st projectStore) {
+	serveEnvironmentHealthAPI(datadir, st)
+}
+
+// same checks as `devco doctor`
+func serveEnvironmentHealthAPI(datadir string, st projectStore) {
+	type result struct {
+		// no check has status error
+		Ok     bool
+		Checks []checkResult
+	}
+
+	http.HandleFunc("GET /api/health/environment", func(w http.ResponseWriter, r *http.Request) {
+		checks := checkEnvironment(datadir, st)
+		res := result{Ok: true, Checks: checks}
+		for _, c := range checks {
+			if c.Status == checkError {
+				res.Ok = false
+			}
+		}
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode result: %s", err)
+			return
+		}
+
+		w.Write(b)
+	})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0x5341/devco/devcontainer"
+)
+
+type jobStatus string
+
+const (
+	jobRunning   jobStatus = "running"
+	jobSucceeded jobStatus = "succeeded"
+	jobFailed    jobStatus = "failed"
+	// stopped by jobStore.cancel
+	jobCanceled jobStatus = "canceled"
+)
+
+type jobKind string
+
+const (
+	jobLaunch          jobKind = "launch"
+	jobRebuild         jobKind = "rebuild"
+	jobDown            jobKind = "down"
+	jobStop            jobKind = "stop"
+	jobStart           jobKind = "start"
+	jobDeleteWorkspace jobKind = "deleteWorkspace"
+)
+
+// how long finished jobs are kept
+const jobRetention = time.Hour
+
+// long running operation on a workspace
+type job struct {
+	Id            string
+	Kind          jobKind
+	ProjectName   string
+	WorkspaceName string
+
+	Status jobStatus
+	// last progress message
+	Progress string
+	// result of the job (when succeeded)
+	Result any
+	// error message (when failed)
+	Error string
+	// structured error (like devcontainer.UpError) when available
+	ErrorDetail any `json:",omitempty"`
+
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	// running jobs
+	wg sync.WaitGroup
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*job)}
+}
+
+// start fn in background as new job.
+// ctx passed to fn is canceled by cancel and shutdown.
+// fails if another job is running on the same workspace.
+func (s *jobStore) start(kind jobKind, pjname string, wsname string, fn func(ctx context.Context, progress func(string)) (any, error)) (job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, j := range s.jobs {
+		if j.FinishedAt != nil && time.Since(*j.FinishedAt) > jobRetention {
+			delete(s.jobs, id)
+			continue
+		}
+		if j.Status == jobRunning && j.ProjectName == pjname && j.WorkspaceName == wsname {
+			return job{}, fmt.Errorf("job `%s` (%s) is already running on workspace `%s` in project `%s`", j.Id, j.Kind, wsname, pjname)
+		}
+	}
+
+	id, err := newJobId()
+	if err != nil {
+		return job{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Id:            id,
+		Kind:          kind,
+		ProjectName:   pjname,
+		WorkspaceName: wsname,
+		Status:        jobRunning,
+		CreatedAt:     time.Now(),
+		cancel:        cancel,
+	}
+	s.jobs[id] = j
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		res, err := fn(ctx, func(msg string) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			j.Progress = msg
+		})
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil && j.canceled {
+			j.Status = jobCanceled
+			j.Error = err.Error()
+			j.ErrorDetail = errorDetail(err)
+			log.Printf("job `%s` (%s) on workspace `%s` in project `%s` canceled: %s", j.Id, j.Kind, wsname, pjname, err)
+			return
+		}
+		if err != nil {
+			j.Status = jobFailed
+			j.Error = err.Error()
+			j.ErrorDetail = errorDetail(err)
+			log.Printf("job `%s` (%s) on workspace `%s` in project `%s` failed: %s", j.Id, j.Kind, wsname, pjname, err)
+			return
+		}
+		j.Status = jobSucceeded
+		j.Result = res
+	}()
+
+	return *j, nil
+}
+
+func (s *jobStore) get(id string) (job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// cancel the running job of one of kinds on the workspace
+func (s *jobStore) cancel(pjname string, wsname string, kinds ...jobKind) (job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.Status == jobRunning && j.ProjectName == pjname && j.WorkspaceName == wsname && slices.Contains(kinds, j.Kind) {
+			j.canceled = true
+			j.cancel()
+			return *j, true
+		}
+	}
+	return job{}, false
+}
+
+// cancel all running jobs and wait until they finish their cleanup
+func (s *jobStore) shutdown() {
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		if j.Status == jobRunning {
+			j.canceled = true
+			j.cancel()
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// JSON form of err for clients to show what failed. nil if err has no structure
+func errorDetail(err error) any {
+	var ue *devcontainer.UpError
+	if errors.As(err, &ue) {
+		return ue
+	}
+	return nil
+}
+
+// ctx with timeout d. d <= 0 means no timeout
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func newJobId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generate job id: %s", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func serveJobAPI(jobs *jobStore) {
+	http.HandleFunc("GET /api/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, ok := jobs.get(r.PathValue("id"))
+		if !ok {
+			errPrint(w, http.StatusNotFound, "error job `%s` not found", r.PathValue("id"))
+			return
+		}
+
+		b, err := json.Marshal(j)
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode job: %s", err)
+			return
+		}
+
+		w.Write(b)
+	})
+}
+
+// start job and respond `202 Accepted` with the job id
+func acceptJob(w http.ResponseWriter, jobs *jobStore, kind jobKind, pjname string, wsname string, fn func(ctx context.Context, progress func(string)) (any, error)) {
+	j, err := jobs.start(kind, pjname, wsname, fn)
+	if err != nil {
+		errPrint(w, http.StatusConflict, "error start job: %s", err)
+		return
+	}
+
+	b, err := json.Marshal(struct{ JobId string }{j.Id})
+	if err != nil {
+		errPrint(w, http.StatusInternalServerError, "error encode job: %s", err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+j.Id)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(b)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0x5341/devco/devcontainer"
+)
+
+type logLine struct {
+	Stream devcontainer.LogStream
+	Text   string
+	Time   time.Time
+}
+
+// output of the latest launch (or start) of one workspace.
+// kept in memory after the launch finished (or failed) until the next launch or start.
+type workspaceLog struct {
+	mu    sync.Mutex
+	lines []logLine
+	done  bool
+	err   string
+	// structured err (like *devcontainer.UpError). nil if not available
+	detail any
+	// closed and replaced every time the log changes
+	changed chan struct{}
+}
+
+func (l *workspaceLog) append(stream devcontainer.LogStream, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, logLine{Stream: stream, Text: text, Time: time.Now()})
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (l *workspaceLog) finish(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.done = true
+	if err != nil {
+		l.err = err.Error()
+		l.detail = errorDetail(err)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// lines after `since`, and a channel closed on the next change
+func (l *workspaceLog) read(since int) (lines []logLine, done bool, errmsg string, detail any, changed <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if since < len(l.lines) {
+		lines = append(lines, l.lines[max(since, 0):]...)
+	}
+	return lines, l.done, l.err, l.detail, l.changed
+}
+
+type logStore struct {
+	mu   sync.Mutex
+	logs map[string]*workspaceLog
+}
+
+func newLogStore() *logStore {
+	return &logStore{logs: make(map[string]*workspaceLog)}
+}
+
+// start new log for the workspace. previous log is discarded.
+func (s *logStore) start(pjname string, wsname string) *workspaceLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := &workspaceLog{changed: make(chan struct{})}
+	s.logs[workspaceKey(pjname, wsname)] = l
+	return l
+}
+
+func (s *logStore) get(pjname string, wsname string) (*workspaceLog, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.logs[workspaceKey(pjname, wsname)]
+	return l, ok
+}
+
+func serveLogAPI(logs *logStore) {
+	serveGetLaunchLogAPI(logs)
+	serveStreamLaunchLogAPI(logs)
+}
+
+// polling endpoint. returns lines from `since` and the index to pass as `since` next time.
+func serveGetLaunchLogAPI(logs *logStore) {
+	type response struct {
+		Lines []logLine
+		Next  int
+		Done  bool
+		Error string
+		// structured error (like devcontainer.UpError) when available
+		ErrorDetail any `json:",omitempty"`
+	}
+
+	http.HandleFunc("GET /api/workspace/launch/log", func(w http.ResponseWriter, r *http.Request) {
+		l, since, ok := launchLogFromRequest(w, r, logs)
+		if !ok {
+			return
+		}
+
+		lines, done, errmsg, detail, _ := l.read(since)
+		b, err := json.Marshal(response{
+			Lines:       lines,
+			Next:        max(since, 0) + len(lines),
+			Done:        done,
+			Error:       errmsg,
+			ErrorDetail: detail,
+		})
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode launch log: %s", err)
+			return
+		}
+
+		w.Write(b)
+	})
+}
+
+// Server-Sent Events endpoint. each line is sent as `log` event, and `done` event is sent at the end.
+func serveStreamLaunchLogAPI(logs *logStore) {
+	http.HandleFunc("GET /api/workspace/launch/log/stream", func(w http.ResponseWriter, r *http.Request) {
+		l, since, ok := launchLogFromRequest(w, r, logs)
+		if !ok {
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errPrint(w, http.StatusInternalServerError, "error streaming is not supported")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		next := max(since, 0)
+		for {
+			lines, done, errmsg, detail, changed := l.read(next)
+			for _, line := range lines {
+				b, err := json.Marshal(line)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", next, b)
+				next++
+			}
+
+			if done {
+				b, _ := json.Marshal(struct {
+					Error       string
+					ErrorDetail any `json:",omitempty"`
+				}{errmsg, detail})
+				fmt.Fprintf(w, "event: done\ndata: %s\n\n", b)
+				flusher.Flush()
+				return
+			}
+			flusher.Flush()
+
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+func launchLogFromRequest(w http.ResponseWriter, r *http.Request, logs *logStore) (*workspaceLog, int, bool) {
+	for _, p := range []string{"pjname", "wsname"} {
+		if !r.URL.Query().Has(p) {
+			errPrint(w, http.StatusBadRequest, "error paramater `%s` is not exist", p)
+			return nil, 0, false
+		}
+	}
+
+	pjname := r.URL.Query().Get("pjname")
+	wsname := r.URL.Query().Get("wsname")
+
+	since := 0
+	if s := r.URL.Query().Get("since"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error paramater `since` is not number: %s", err)
+			return nil, 0, false
+		}
+		since = v
+	}
+	// EventSource sends the last received id on reconnect
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			since = v + 1
+		}
+	}
+
+	l, ok := logs.get(pjname, wsname)
+	if !ok {
+		errPrint(w, http.StatusNotFound, "error no launch log for workspace `%s` in project `%s`", wsname, pjname)
+		return nil, 0, false
+	}
+
+	return l, since, true
+}
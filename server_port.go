@@ -1,23 +1,39 @@
 package main
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
+	"time"
 )
 
-func servePortAccessAPI(datadir string) {
+func servePortAPI(st projectStore, conf config, auth *authManager, scanner *portScanner, shares *portShares) {
+	servePortAccessAPI(st, auth, scanner, conf.ProxyAllPorts)
+	serveDetectedPortsAPI(st, scanner)
+	servePortVisibilityAPI(st)
+	servePortShareAPI(st, conf.PortDomain, shares)
+}
+
+func servePortAccessAPI(st projectStore, auth *authManager, scanner *portScanner, allowAll bool) {
 	http.HandleFunc("/port/{pid}/{wid}/{port}/{rest...}", func(w http.ResponseWriter, r *http.Request) {
-		js, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
 		pid := r.PathValue("pid")
 		wid := r.PathValue("wid")
-		port := r.PathValue("port")
 		rest := r.PathValue("rest")
 
+		port, err := strconv.ParseUint(r.PathValue("port"), 10, 16)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error invalid port `%s`", r.PathValue("port"))
+			return
+		}
+
 		if _, ok = js[pid]; !ok {
 			errPrint(w, http.StatusBadRequest, "error project `%s` not exists", pid)
 			return
@@ -28,19 +44,245 @@ func servePortAccessAPI(datadir string) {
 			return
 		}
 
-		proxy := &httputil.ReverseProxy{
-			Rewrite: func(pr *httputil.ProxyRequest) {
-				pr.Out.URL = &url.URL{
-					Scheme:   "http",
-					Host:     fmt.Sprintf("%s:%s", js[pid].Workspaces[wid].IPAddress, port),
-					Path:     "/" + rest,
-					RawQuery: r.URL.RawQuery,
-				}
-				pr.SetXForwarded()
-				pr.Out.Header.Add("X-Forwarded-Prefix", fmt.Sprintf("/port/%s/%s/%s/%s", pid, wid, port, rest))
-			},
+		ws := js[pid].Workspaces[wid]
+		if err := checkPortAccess(r.Context(), ws, pid, wid, int(port), scanner, allowAll); err != nil {
+			jsonHelper[struct{}](w)(struct{}{}, err)
+			return
+		}
+
+		proxyToWorkspace(w, r, auth, ws, int(port), "/"+rest, fmt.Sprintf("/port/%s/%s/%d/%s", pid, wid, port, rest))
+	})
+}
+
+// set who can access a port of the workspace
+func servePortVisibilityAPI(st projectStore) {
+	type conf struct {
+		ProjectName   string
+		WorkspaceName string
+		Port          int
+		Visibility    string
+	}
+
+	http.HandleFunc("POST /api/workspace/port/visibility", func(w http.ResponseWriter, r *http.Request) {
+		var c conf
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
+			return
+		}
+
+		if c.Port <= 0 || c.Port > 65535 {
+			errPrint(w, http.StatusBadRequest, "error invalid port `%d`", c.Port)
+			return
+		}
+
+		v, err := parsePortVisibility(c.Visibility)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error %s", err)
+			return
+		}
+
+		_, ok := jsonHelper[struct{}](w)(updateWorkspace(st, c.ProjectName, c.WorkspaceName, func(ws *projectsJsonWorkspace) error {
+			if ws.PortVisibility == nil {
+				ws.PortVisibility = make(map[int]portVisibility)
+			}
+			ws.PortVisibility[c.Port] = v
+			return nil
+		}))
+		if !ok {
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// issue a share link of a shared port
+func servePortShareAPI(st projectStore, portDomain string, shares *portShares) {
+	type conf struct {
+		ProjectName   string
+		WorkspaceName string
+		Port          int
+		// lifetime of the link (like "1h"). default: 24h
+		ExpiresIn string
+	}
+
+	type result struct {
+		URL       string
+		ExpiresAt time.Time
+	}
+
+	http.HandleFunc("POST /api/workspace/port/share", func(w http.ResponseWriter, r *http.Request) {
+		var c conf
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
+			return
+		}
+
+		expiresIn := defaultShareExpiresIn
+		if c.ExpiresIn != "" {
+			expiresIn, err = time.ParseDuration(c.ExpiresIn)
+			if err != nil || expiresIn <= 0 {
+				errPrint(w, http.StatusBadRequest, "error invalid ExpiresIn `%s`", c.ExpiresIn)
+				return
+			}
+		}
+
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
+		if !ok {
+			return
+		}
+
+		if _, ok := js[c.ProjectName]; !ok {
+			errPrint(w, http.StatusNotFound, "error project `%s` not exists", c.ProjectName)
+			return
+		}
+
+		if _, ok := js[c.ProjectName].Workspaces[c.WorkspaceName]; !ok {
+			errPrint(w, http.StatusNotFound, "error workspace `%s` not exists in project `%s`", c.WorkspaceName, c.ProjectName)
+			return
+		}
+
+		if js[c.ProjectName].Workspaces[c.WorkspaceName].portVisibility(c.Port) != visibilityShared {
+			errPrint(w, http.StatusBadRequest, "error port %d of workspace `%s` is not shared", c.Port, c.WorkspaceName)
+			return
 		}
 
-		proxy.ServeHTTP(w, r)
+		expires := time.Now().Add(expiresIn)
+		token := shares.token(c.ProjectName, c.WorkspaceName, c.Port, expires)
+
+		b, err := json.Marshal(result{
+			URL:       shareURL(r, portDomain, c.ProjectName, c.WorkspaceName, c.Port, token),
+			ExpiresAt: time.Unix(expires.Unix(), 0),
+		})
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode result: %s", err)
+			return
+		}
+
+		w.Write(b)
 	})
 }
+
+// listening ports in the workspace container
+func serveDetectedPortsAPI(st projectStore, scanner *portScanner) {
+	http.HandleFunc("GET /api/workspace/ports", func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range []string{"pjname", "wsname"} {
+			if !r.URL.Query().Has(p) {
+				errPrint(w, http.StatusBadRequest, "error paramater `%s` is not exist", p)
+				return
+			}
+		}
+
+		pjname := r.URL.Query().Get("pjname")
+		wsname := r.URL.Query().Get("wsname")
+
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
+		if !ok {
+			return
+		}
+
+		if _, ok := js[pjname]; !ok {
+			errPrint(w, http.StatusBadRequest, "error project `%s` is not exist", pjname)
+			return
+		}
+
+		if _, ok := js[pjname].Workspaces[wsname]; !ok {
+			errPrint(w, http.StatusBadRequest, "error workspace `%s` is not exist in project `%s`", wsname, pjname)
+			return
+		}
+
+		if js[pjname].Workspaces[wsname].State != stateRunning {
+			errPrint(w, http.StatusBadRequest, "error workspace `%s` is not running", wsname)
+			return
+		}
+
+		// not scanned yet (just launched, or periodic scan is disabled)
+		res, ok := scanner.get(pjname, wsname)
+		if !ok {
+			var err error
+			res, err = scanner.scan(r.Context(), pjname, wsname, js[pjname].Workspaces[wsname].ContainerId)
+			if err != nil {
+				errPrint(w, http.StatusInternalServerError, "error detect ports: %s", err)
+				return
+			}
+		}
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode ports: %s", err)
+			return
+		}
+
+		w.Write(b)
+	})
+}
+
+// reverse proxy the request to port of the workspace container.
+// prefix is sent as X-Forwarded-Prefix if not empty.
+func proxyToWorkspace(w http.ResponseWriter, r *http.Request, auth *authManager, ws projectsJsonWorkspace, port int, p string, prefix string) {
+	addr, ok := jsonHelper[string](w)(ws.portAddress(port))
+	if !ok {
+		return
+	}
+	scheme := ws.portProtocol(port)
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = &url.URL{
+				Scheme:   scheme,
+				Host:     addr,
+				Path:     p,
+				RawQuery: stripShareParam(r.URL.RawQuery),
+			}
+			stripSessionCookie(pr.Out)
+			auth.stripCredentials(pr.Out)
+			pr.SetXForwarded()
+			if prefix != "" {
+				pr.Out.Header.Add("X-Forwarded-Prefix", prefix)
+			}
+		},
+		ModifyResponse: func(res *http.Response) error {
+			scopeCookies(res)
+			return nil
+		},
+	}
+	if scheme == "https" {
+		// apps in containers usually use self-signed certificates
+		proxy.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	proxy.ServeHTTP(w, r)
+}
+
+// do not pass devco's session (and share token) to the apps in containers
+func stripSessionCookie(r *http.Request) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name == sessionCookieName || c.Name == shareCookieName {
+			continue
+		}
+		r.AddCookie(c)
+	}
+}
+
+// make cookies set by apps host-only, so an app can not set cookies
+// for other workspaces (or devco itself) with Domain attribute.
+func scopeCookies(res *http.Response) {
+	lines := res.Header.Values("Set-Cookie")
+	if len(lines) == 0 {
+		return
+	}
+
+	res.Header.Del("Set-Cookie")
+	for _, line := range lines {
+		c, err := http.ParseSetCookie(line)
+		if err != nil || c.Name == sessionCookieName || c.Name == shareCookieName {
+			continue
+		}
+		c.Domain = ""
+		res.Header.Add("Set-Cookie", c.String())
+	}
+}
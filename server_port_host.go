@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// strip port of host
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// host is domain or its subdomain
+func hostMatches(host string, domain string) bool {
+	h := strings.ToLower(hostname(host))
+	d := strings.ToLower(domain)
+	return h == d || strings.HasSuffix(h, "."+d)
+}
+
+// `{port}-{wsname}-{pjname}` part of `{port}-{wsname}-{pjname}.<base>`
+func portHostLabel(host string, base string) (string, bool) {
+	label, ok := strings.CutSuffix(strings.ToLower(hostname(host)), "."+strings.ToLower(base))
+	if !ok || label == "" || strings.Contains(label, ".") {
+		return "", false
+	}
+	return label, true
+}
+
+// find the workspace of label.
+// names can contain `-`, so every workspace is compared with `{wsname}-{pjname}`.
+// host names are case-insensitive, so names are compared case-insensitively.
+func resolvePortHost(label string, js projectsJson) (pjname string, wsname string, port int, err error) {
+	portstr, rest, ok := strings.Cut(label, "-")
+	if !ok {
+		return "", "", 0, statusErrorf(http.StatusBadRequest, "error invalid host `%s`", label)
+	}
+	n, err := strconv.ParseUint(portstr, 10, 16)
+	if err != nil {
+		return "", "", 0, statusErrorf(http.StatusBadRequest, "error invalid port `%s`", portstr)
+	}
+	port = int(n)
+
+	found := 0
+	for pn, p := range js {
+		for wn := range p.Workspaces {
+			if strings.EqualFold(wn+"-"+pn, rest) {
+				pjname, wsname = pn, wn
+				found++
+			}
+		}
+	}
+
+	switch found {
+	case 0:
+		return "", "", 0, statusErrorf(http.StatusNotFound, "error workspace for host `%s` not found", label)
+	case 1:
+		return pjname, wsname, port, nil
+	default:
+		return "", "", 0, statusErrorf(http.StatusBadRequest, "error host `%s` matches multiple workspaces", label)
+	}
+}
+
+// route `{port}-{wsname}-{pjname}.<base>` to the port of the workspace.
+// other hosts go to next. any path on the port host is proxied, so apps that
+// use absolute URLs (like `/assets/...`) work without the /port/ prefix.
+// without login, shared ports can be accessed with share links.
+func portHostMiddleware(base string, st projectStore, auth *authManager, shares *portShares, scanner *portScanner, allowAll bool, next http.Handler) http.Handler {
+	if base == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		label, ok := portHostLabel(r.Host, base)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authenticated := true
+		if auth != nil {
+			_, authenticated = auth.authenticate(r)
+		}
+		// do not tell which workspaces exist to anonymous users
+		if !authenticated && !hasShareToken(r) {
+			redirectToLogin(w, r, base)
+			return
+		}
+
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
+		if !ok {
+			return
+		}
+
+		pjname, wsname, port, err := resolvePortHost(label, js)
+		if err != nil {
+			if !authenticated {
+				redirectToLogin(w, r, base)
+				return
+			}
+			jsonHelper[struct{}](w)(struct{}{}, err)
+			return
+		}
+
+		if !authenticated && !shares.allow(w, r, pjname, wsname, port, "/") {
+			redirectToLogin(w, r, base)
+			return
+		}
+
+		ws := js[pjname].Workspaces[wsname]
+		if err := checkPortAccess(r.Context(), ws, pjname, wsname, port, scanner, allowAll); err != nil {
+			jsonHelper[struct{}](w)(struct{}{}, err)
+			return
+		}
+
+		proxyToWorkspace(w, r, auth, ws, port, r.URL.Path, "")
+	})
+}
+
+// send browsers on a port host to the login page on the base domain
+func redirectToLogin(w http.ResponseWriter, r *http.Request, base string) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="devco"`)
+		errPrint(w, http.StatusUnauthorized, "error authentication required")
+		return
+	}
+
+	host := base
+	if _, port, err := net.SplitHostPort(r.Host); err == nil {
+		host = net.JoinHostPort(base, port)
+	}
+
+	next := scheme + "://" + r.Host + r.URL.RequestURI()
+	http.Redirect(w, r, scheme+"://"+host+"/login?next="+url.QueryEscape(next), http.StatusFound)
+}
@@ -5,20 +5,27 @@ import (
 	"net/http"
 )
 
-func serveProjectAPI(datadir string) {
-	serveGetProjectAPI(datadir)
-	servePostProjectAPI(datadir)
-	serveDeleteProjectAPI(datadir)
+func serveProjectAPI(st projectStore) {
+	serveGetProjectAPI(st)
+	servePostProjectAPI(st)
+	serveDeleteProjectAPI(st)
+	serveProjectMountsAPI(st)
 }
 
-func serveGetProjectAPI(datadir string) {
+func serveGetProjectAPI(st projectStore) {
 	http.HandleFunc("GET /api/project", func(w http.ResponseWriter, r *http.Request) {
-		file, ok := jsonHelper[[]byte](w)(loadProjectsJsonRaw(datadir))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
 
-		_, err := w.Write(file)
+		file, err := json.Marshal(js)
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode projects: %s", err)
+			return
+		}
+
+		_, err = w.Write(file)
 		if err != nil {
 			errPrint(w, http.StatusInternalServerError, "error response write: %s", err)
 			return
@@ -26,10 +33,11 @@ func serveGetProjectAPI(datadir string) {
 	})
 }
 
-func servePostProjectAPI(datadir string) {
+func servePostProjectAPI(st projectStore) {
 	type createProjectRequest struct {
-		Name string
-		Path string
+		Name   string
+		Path   string
+		Mounts []mount
 	}
 
 	http.HandleFunc("POST /api/project", func(w http.ResponseWriter, r *http.Request) {
@@ -40,22 +48,25 @@ func servePostProjectAPI(datadir string) {
 			return
 		}
 
-		file, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
-		if !ok {
-			return
-		}
-
-		if _, ok := file[c.Name]; ok {
-			errPrint(w, http.StatusBadRequest, "error add project: project `%s` exists", c.Name)
-			return
-		}
-
-		file[c.Name] = projectsJsonProject{
-			Path:       c.Path,
-			Workspaces: map[string]projectsJsonWorkspace{},
+		for _, m := range c.Mounts {
+			if err := m.validate(); err != nil {
+				errPrint(w, http.StatusBadRequest, "error %s", err)
+				return
+			}
 		}
 
-		_, ok = jsonHelper[struct{}](w)(writeProjectsJson(datadir, file))
+		_, ok := jsonHelper[struct{}](w)(st.Update(func(file projectsJson) error {
+			if _, ok := file[c.Name]; ok {
+				return statusErrorf(http.StatusBadRequest, "error add project: project `%s` exists", c.Name)
+			}
+
+			file[c.Name] = projectsJsonProject{
+				Path:       c.Path,
+				Mounts:     c.Mounts,
+				Workspaces: map[string]projectsJsonWorkspace{},
+			}
+			return nil
+		}))
 		if !ok {
 			return
 		}
@@ -64,7 +75,7 @@ func servePostProjectAPI(datadir string) {
 	})
 }
 
-func serveDeleteProjectAPI(datadir string) {
+func serveDeleteProjectAPI(st projectStore) {
 	http.HandleFunc("DELETE /api/project", func(w http.ResponseWriter, r *http.Request) {
 		if !r.URL.Query().Has("pjname") {
 			errPrint(w, http.StatusBadRequest, "error get pjname")
@@ -72,19 +83,54 @@ func serveDeleteProjectAPI(datadir string) {
 		}
 		pjname := r.URL.Query().Get("pjname")
 
-		json, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
+		_, ok := jsonHelper[struct{}](w)(st.Update(func(json projectsJson) error {
+			if _, ok := json[pjname]; !ok {
+				return statusErrorf(http.StatusBadRequest, "error project `%s` is not found", pjname)
+			}
+
+			delete(json, pjname)
+			return nil
+		}))
 		if !ok {
 			return
 		}
 
-		if _, ok = json[pjname]; !ok {
-			errPrint(w, http.StatusBadRequest, "error project `%s` is not found", pjname)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// replace mounts of the project. applied from the next launch of each workspace
+func serveProjectMountsAPI(st projectStore) {
+	type conf struct {
+		ProjectName string
+		Mounts      []mount
+	}
+
+	http.HandleFunc("POST /api/project/mounts", func(w http.ResponseWriter, r *http.Request) {
+		var c conf
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
 			return
 		}
 
-		delete(json, pjname)
+		for _, m := range c.Mounts {
+			if err := m.validate(); err != nil {
+				errPrint(w, http.StatusBadRequest, "error %s", err)
+				return
+			}
+		}
+
+		_, ok := jsonHelper[struct{}](w)(st.Update(func(js projectsJson) error {
+			p, ok := js[c.ProjectName]
+			if !ok {
+				return statusErrorf(http.StatusNotFound, "error project `%s` is not found", c.ProjectName)
+			}
 
-		_, ok = jsonHelper[struct{}](w)(writeProjectsJson(datadir, json))
+			p.Mounts = c.Mounts
+			js[c.ProjectName] = p
+			return nil
+		}))
 		if !ok {
 			return
 		}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// manage authorized keys of the SSH gateway
+func serveSSHAPI(keys *authorizedKeys, gateway *sshGateway) {
+	http.HandleFunc("GET /api/ssh/keys", func(w http.ResponseWriter, r *http.Request) {
+		list, ok := jsonHelper[[]authorizedKey](w)(keys.list())
+		if !ok {
+			return
+		}
+		if list == nil {
+			list = []authorizedKey{}
+		}
+
+		b, err := json.Marshal(list)
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode keys: %s", err)
+			return
+		}
+
+		w.Write(b)
+	})
+
+	http.HandleFunc("POST /api/ssh/keys", func(w http.ResponseWriter, r *http.Request) {
+		var c struct {
+			// line of authorized_keys (`ssh-ed25519 AAAA... comment`)
+			Key string
+		}
+		err := json.NewDecoder(r.Body).Decode(&c)
+		if err != nil {
+			errPrint(w, http.StatusBadRequest, "error decode request body: %s", err)
+			return
+		}
+
+		key, ok := jsonHelper[authorizedKey](w)(keys.add(c.Key))
+		if !ok {
+			return
+		}
+
+		b, err := json.Marshal(key)
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode key: %s", err)
+			return
+		}
+
+		w.Write(b)
+	})
+
+	http.HandleFunc("DELETE /api/ssh/keys", func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.Query().Has("fingerprint") {
+			errPrint(w, http.StatusBadRequest, "error paramater `fingerprint` is not exist")
+			return
+		}
+
+		_, ok := jsonHelper[struct{}](w)(struct{}{}, keys.remove(r.URL.Query().Get("fingerprint")))
+		if !ok {
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// for known_hosts
+	http.HandleFunc("GET /api/ssh/hostkey", func(w http.ResponseWriter, r *http.Request) {
+		if gateway == nil {
+			errPrint(w, http.StatusNotFound, "error SSH gateway is disabled")
+			return
+		}
+
+		pub := gateway.hostKey.PublicKey()
+		b, err := json.Marshal(struct {
+			Key         string
+			Fingerprint string
+		}{
+			Key:         strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+			Fingerprint: ssh.FingerprintSHA256(pub),
+		})
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode host key: %s", err)
+			return
+		}
+
+		w.Write(b)
+	})
+}
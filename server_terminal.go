@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+
+	"github.com/0x5341/devco/devcontainer"
+	"github.com/coder/websocket"
+	"github.com/creack/pty"
+)
+
+// shell started in the container. prefer bash and fallback to sh.
+const terminalShell = "if command -v bash >/dev/null 2>&1; then exec bash -l; else exec sh -l; fi"
+
+// control messages are small. larger ones are discarded
+const maxTerminalControlSize = 1024
+
+// control message sent by the client as a websocket text message.
+// binary messages are raw terminal input (client to server) and output (server to client).
+type terminalControl struct {
+	// "resize"
+	Type string
+	Cols uint16
+	Rows uint16
+}
+
+func serveTerminalAPI(st projectStore) {
+	http.HandleFunc("GET /api/workspace/terminal", func(w http.ResponseWriter, r *http.Request) {
+		checkParam := func(name string) bool {
+			if !r.URL.Query().Has(name) {
+				errPrint(w, http.StatusBadRequest, "error paramater `%s` is not exist", name)
+				return false
+			}
+			return true
+		}
+
+		for _, p := range []string{"pjname", "wsname"} {
+			if !checkParam(p) {
+				return
+			}
+		}
+
+		pjname := r.URL.Query().Get("pjname")
+		wsname := r.URL.Query().Get("wsname")
+
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
+		if !ok {
+			return
+		}
+
+		if _, ok := js[pjname]; !ok {
+			errPrint(w, http.StatusBadRequest, "error project `%s` is not exist", pjname)
+			return
+		}
+
+		if _, ok := js[pjname].Workspaces[wsname]; !ok {
+			errPrint(w, http.StatusBadRequest, "error workspace `%s` is not exist in project `%s`", wsname, pjname)
+			return
+		}
+
+		if js[pjname].Workspaces[wsname].State != stateRunning {
+			errPrint(w, http.StatusBadRequest, "error workspace `%s` is not running", wsname)
+			return
+		}
+
+		size := &pty.Winsize{Cols: 80, Rows: 24}
+		if v, err := strconv.ParseUint(r.URL.Query().Get("cols"), 10, 16); err == nil && v > 0 {
+			size.Cols = uint16(v)
+		}
+		if v, err := strconv.ParseUint(r.URL.Query().Get("rows"), 10, 16); err == nil && v > 0 {
+			size.Rows = uint16(v)
+		}
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			log.Printf("error accept terminal websocket: %s", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		err = runTerminal(r.Context(), conn, js[pjname].Workspaces[wsname].Path, size)
+		if err != nil {
+			log.Printf("terminal on workspace `%s` in project `%s` closed: %s", wsname, pjname, err)
+			conn.Close(websocket.StatusInternalError, "terminal error")
+			return
+		}
+
+		conn.Close(websocket.StatusNormalClosure, "")
+	})
+}
+
+// run shell in the workspace container and connect it to the websocket until either side closes.
+// each call starts its own `devcontainer exec`, so any number of terminals can be opened per workspace.
+func runTerminal(ctx context.Context, conn *websocket.Conn, workspace string, size *pty.Winsize) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := devcontainer.ExecWith(devcontainer.ExecConfig{
+		DockerPath:      containerRuntime.DockerPath,
+		DockerHost:      containerRuntime.Socket,
+		WorkspaceFolder: workspace,
+	}, "sh", "-c", terminalShell)
+	cmd.Env = append(cmd.Environ(), "TERM=xterm-256color")
+
+	tty, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	// stop the shell when the connection is gone
+	go func() {
+		<-ctx.Done()
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+	}()
+
+	// client -> pty.
+	// input (like a large paste) is streamed to the pty, so it has no size limit
+	conn.SetReadLimit(-1)
+	go func() {
+		defer cancel()
+		for {
+			ty, r, err := conn.Reader(ctx)
+			if err != nil {
+				return
+			}
+
+			switch ty {
+			case websocket.MessageBinary:
+				if _, err := io.Copy(tty, r); err != nil {
+					return
+				}
+			case websocket.MessageText:
+				b, err := io.ReadAll(io.LimitReader(r, maxTerminalControlSize+1))
+				if err != nil {
+					return
+				}
+				if len(b) > maxTerminalControlSize {
+					log.Printf("error terminal control is larger than %d bytes", maxTerminalControlSize)
+					if _, err := io.Copy(io.Discard, r); err != nil {
+						return
+					}
+					continue
+				}
+
+				var c terminalControl
+				if err := json.Unmarshal(b, &c); err != nil {
+					log.Printf("error decode terminal control: %s", err)
+					continue
+				}
+				if c.Type == "resize" && c.Cols > 0 && c.Rows > 0 {
+					err := pty.Setsize(tty, &pty.Winsize{Cols: c.Cols, Rows: c.Rows})
+					if err != nil {
+						log.Printf("error resize terminal: %s", err)
+					}
+				}
+			}
+		}
+	}()
+
+	// pty -> client
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := tty.Read(buf)
+		if n > 0 {
+			if werr := conn.Write(ctx, websocket.MessageBinary, buf[:n]); werr != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	cancel()
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+const tunnelDialTimeout = 10 * time.Second
+
+// raw TCP connection to a port of the workspace over websocket (binary messages).
+// used by `devco forward`.
+func serveTunnelAPI(st projectStore, scanner *portScanner, allowAll bool) {
+	http.HandleFunc("GET /api/workspace/tunnel", func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range []string{"pjname", "wsname", "port"} {
+			if !r.URL.Query().Has(p) {
+				errPrint(w, http.StatusBadRequest, "error paramater `%s` is not exist", p)
+				return
+			}
+		}
+
+		pjname := r.URL.Query().Get("pjname")
+		wsname := r.URL.Query().Get("wsname")
+		port, err := strconv.ParseUint(r.URL.Query().Get("port"), 10, 16)
+		if err != nil || port == 0 {
+			errPrint(w, http.StatusBadRequest, "error invalid port `%s`", r.URL.Query().Get("port"))
+			return
+		}
+
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
+		if !ok {
+			return
+		}
+
+		if _, ok := js[pjname]; !ok {
+			errPrint(w, http.StatusBadRequest, "error project `%s` is not exist", pjname)
+			return
+		}
+
+		if _, ok := js[pjname].Workspaces[wsname]; !ok {
+			errPrint(w, http.StatusBadRequest, "error workspace `%s` is not exist in project `%s`", wsname, pjname)
+			return
+		}
+
+		ws := js[pjname].Workspaces[wsname]
+		if err := checkPortAccess(r.Context(), ws, pjname, wsname, int(port), scanner, allowAll); err != nil {
+			jsonHelper[struct{}](w)(struct{}{}, err)
+			return
+		}
+
+		// dial before upgrade to report failure as HTTP status
+		target, ok := jsonHelper[string](w)(ws.portAddress(int(port)))
+		if !ok {
+			return
+		}
+		tcp, err := net.DialTimeout("tcp", target, tunnelDialTimeout)
+		if err != nil {
+			errPrint(w, http.StatusBadGateway, "error connect to `%s`: %s", target, err)
+			return
+		}
+		defer tcp.Close()
+
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			log.Printf("error accept tunnel websocket: %s", err)
+			return
+		}
+		defer conn.CloseNow()
+
+		err = pipe(r.Context(), conn, tcp)
+		if err != nil {
+			log.Printf("tunnel to `%s` closed: %s", target, err)
+		}
+		conn.Close(websocket.StatusNormalClosure, "")
+	})
+}
+
+// copy data between websocket and tcp until either side closes
+func pipe(ctx context.Context, conn *websocket.Conn, tcp net.Conn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wsconn := websocket.NetConn(ctx, conn, websocket.MessageBinary)
+	defer wsconn.Close()
+
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(wsconn, tcp)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(tcp, wsconn)
+		errc <- err
+	}()
+
+	err := <-errc
+	// unblock the other copy
+	cancel()
+	tcp.Close()
+
+	var ce websocket.CloseError
+	if err == nil || errors.As(err, &ce) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -1,17 +1,20 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"path"
 )
 
 func jsonHelper[T any](w http.ResponseWriter) func(v T, err error) (T, bool) {
 	return func(v T, err error) (T, bool) {
 		if err != nil {
-			errPrint(w, http.StatusInternalServerError, "%s", err)
+			code := http.StatusInternalServerError
+			var se *statusError
+			if errors.As(err, &se) {
+				code = se.code
+			}
+			errPrint(w, code, "%s", err)
 			var r T
 			return r, false
 		}
@@ -19,39 +22,17 @@ func jsonHelper[T any](w http.ResponseWriter) func(v T, err error) (T, bool) {
 	}
 }
 
-func loadProjectsJsonRaw(datadir string) ([]byte, error) {
-	pjpath := path.Join(datadir, "projects.json")
-	file, err := os.ReadFile(pjpath)
-	if err != nil {
-		return nil, fmt.Errorf("error read projects.json: %s", err)
-	}
-	return file, nil
+// error with HTTP status code.
+// returned from [projectStore.Update] functions to reject the request.
+type statusError struct {
+	code int
+	msg  string
 }
 
-func loadProjectsJson(datadir string) (projectsJson, error) {
-	file, err := loadProjectsJsonRaw(datadir)
-	if err != nil {
-		return projectsJson{}, err
-	}
-
-	var j projectsJson
-	err = json.Unmarshal(file, &j)
-	if err != nil {
-		return projectsJson{}, fmt.Errorf("error decode projects.json: %s", err)
-	}
-
-	return j, nil
+func (e *statusError) Error() string {
+	return e.msg
 }
 
-func writeProjectsJson(datadir string, data projectsJson) (struct{}, error) {
-	j, err := json.Marshal(data)
-	if err != nil {
-		return struct{}{}, fmt.Errorf("error encode projects.json: %s", err)
-	}
-
-	err = os.WriteFile(path.Join(datadir, "projects.json"), j, os.ModePerm)
-	if err != nil {
-		return struct{}{}, fmt.Errorf("error write projects.json: %s", err)
-	}
-	return struct{}{}, nil
+func statusErrorf(code int, fmtstr string, v ...any) error {
+	return &statusError{code: code, msg: fmt.Sprintf(fmtstr, v...)}
 }
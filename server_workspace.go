@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,12 +11,13 @@ import (
 	"path"
 )
 
-func serveWorkspaceAPI(datadir string) {
-	servePostWorkspaceAPI(datadir)
-	serveDeleteWorkspaceAPI(datadir)
+func serveWorkspaceAPI(datadir string, st projectStore, timeouts operationTimeouts, jobs *jobStore) {
+	servePostWorkspaceAPI(datadir, st)
+	serveDeleteWorkspaceAPI(datadir, st, timeouts, jobs)
+	serveGetWorkspaceHistoryAPI(st)
 }
 
-func servePostWorkspaceAPI(datadir string) {
+func servePostWorkspaceAPI(datadir string, st projectStore) {
 	type postWorkspaceConfig struct {
 		ProjectName   string
 		WorkspaceName string
@@ -28,7 +31,7 @@ func servePostWorkspaceAPI(datadir string) {
 			return
 		}
 
-		js, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
@@ -38,6 +41,11 @@ func servePostWorkspaceAPI(datadir string) {
 			return
 		}
 
+		if _, ok = js[c.ProjectName].Workspaces[c.WorkspaceName]; ok {
+			errPrint(w, http.StatusConflict, "error workspace `%s` already exists in project `%s`", c.WorkspaceName, c.ProjectName)
+			return
+		}
+
 		var branchName string
 		if c.BranchName != "" {
 			branchName = c.BranchName
@@ -58,14 +66,23 @@ func servePostWorkspaceAPI(datadir string) {
 			return
 		}
 
-		js[c.ProjectName].Workspaces[c.WorkspaceName] = projectsJsonWorkspace{
-			State:       stateBeforeStart,
-			BranchName:  branchName,
-			Path:        path.Join(pjwpath, c.WorkspaceName),
-			ContainerId: "",
-		}
+		_, ok = jsonHelper[struct{}](w)(st.Update(func(js projectsJson) error {
+			if _, ok := js[c.ProjectName]; !ok {
+				return statusErrorf(http.StatusBadRequest, "error Project `%s` not found", c.ProjectName)
+			}
+			// created by another request meanwhile
+			if _, ok := js[c.ProjectName].Workspaces[c.WorkspaceName]; ok {
+				return statusErrorf(http.StatusConflict, "error workspace `%s` already exists in project `%s`", c.WorkspaceName, c.ProjectName)
+			}
 
-		_, ok = jsonHelper[struct{}](w)(writeProjectsJson(datadir, js))
+			js[c.ProjectName].Workspaces[c.WorkspaceName] = projectsJsonWorkspace{
+				State:       stateBeforeStart,
+				BranchName:  branchName,
+				Path:        path.Join(pjwpath, c.WorkspaceName),
+				ContainerId: "",
+			}
+			return nil
+		}))
 		if !ok {
 			return
 		}
@@ -74,7 +91,7 @@ func servePostWorkspaceAPI(datadir string) {
 	})
 }
 
-func serveDeleteWorkspaceAPI(datadir string) {
+func serveDeleteWorkspaceAPI(datadir string, st projectStore, timeouts operationTimeouts, jobs *jobStore) {
 	http.HandleFunc("DELETE /api/workspace", func(w http.ResponseWriter, r *http.Request) {
 		if !r.URL.Query().Has("pjname") {
 			errPrint(w, http.StatusBadRequest, "error `pjname` param not exists")
@@ -89,7 +106,7 @@ func serveDeleteWorkspaceAPI(datadir string) {
 		pjname := r.URL.Query().Get("pjname")
 		wsname := r.URL.Query().Get("wsname")
 
-		js, ok := jsonHelper[projectsJson](w)(loadProjectsJson(datadir))
+		js, ok := jsonHelper[projectsJson](w)(st.Load())
 		if !ok {
 			return
 		}
@@ -104,33 +121,78 @@ func serveDeleteWorkspaceAPI(datadir string) {
 			return
 		}
 
-		if js[pjname].Workspaces[wsname].State == stateRunning {
-			err := downContainer(&js, pjname, wsname)
+		if js[pjname].Workspaces[wsname].State == stateStarting {
+			errPrint(w, http.StatusBadRequest, "error container is starting in workspace `%s`", wsname)
+			return
+		}
+
+		acceptJob(w, jobs, jobDeleteWorkspace, pjname, wsname, func(ctx context.Context, progress func(string)) (any, error) {
+			js, err := st.Load()
+			if err != nil {
+				return nil, err
+			}
+
+			if state := js[pjname].Workspaces[wsname].State; state == stateRunning || state == stateStopped || state == stateFailed {
+				progress("down container")
+				dctx, cancel := withTimeout(ctx, timeouts.Down)
+				err := downContainer(dctx, st, pjname, wsname)
+				cancel()
+				if err != nil {
+					return nil, fmt.Errorf("error failed to down container in workspace `%s` in project `%s`: %s", wsname, pjname, err)
+				}
+			}
+
+			progress("remove worktree")
+			err = exec.Command("git", "-C", js[pjname].Path, "worktree", "remove", "-f", path.Join(datadir, "worktree", pjname, wsname)).Run()
+			if err != nil {
+				return nil, fmt.Errorf("error remove worktree: %s", err)
+			}
+
+			progress("remove branch")
+			err = exec.Command("git", "-C", js[pjname].Path, "branch", "-d", js[pjname].Workspaces[wsname].BranchName).Run()
 			if err != nil {
-				errPrint(w, http.StatusInternalServerError, "error failed to down container in workspace `%s` in project `%s`: %s", wsname, pjname, err)
+				return nil, fmt.Errorf("error remove branch `%s`: %s", js[pjname].Workspaces[wsname].BranchName, err)
+			}
+
+			_, err = st.Update(func(js projectsJson) error {
+				if _, ok := js[pjname]; ok {
+					delete(js[pjname].Workspaces, wsname)
+				}
+				return nil
+			})
+			return nil, err
+		})
+	})
+}
+
+func serveGetWorkspaceHistoryAPI(st projectStore) {
+	http.HandleFunc("GET /api/workspace/history", func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range []string{"pjname", "wsname"} {
+			if !r.URL.Query().Has(p) {
+				errPrint(w, http.StatusBadRequest, "error `%s` param not exists", p)
 				return
 			}
 		}
 
-		err := exec.Command("git", "-C", js[pjname].Path, "worktree", "remove", "-f", path.Join(datadir, "worktree", pjname, wsname)).Run()
-		if err != nil {
-			errPrint(w, http.StatusInternalServerError, "error remove worktree: %s", err)
+		pjname := r.URL.Query().Get("pjname")
+		wsname := r.URL.Query().Get("wsname")
+
+		events, err := st.History(pjname, wsname)
+		if errors.Is(err, errHistoryUnsupported) {
+			errPrint(w, http.StatusNotImplemented, "error %s", err)
 			return
 		}
-
-		err = exec.Command("git", "-C", js[pjname].Path, "branch", "-d", js[pjname].Workspaces[wsname].BranchName).Run()
 		if err != nil {
-			errPrint(w, http.StatusInternalServerError, "error remove branch `%s`: %s", js[pjname].Workspaces[wsname].BranchName, err)
+			errPrint(w, http.StatusInternalServerError, "error get history: %s", err)
 			return
 		}
 
-		delete(js[pjname].Workspaces, wsname)
-
-		_, ok = jsonHelper[struct{}](w)(writeProjectsJson(datadir, js))
-		if !ok {
+		b, err := json.Marshal(events)
+		if err != nil {
+			errPrint(w, http.StatusInternalServerError, "error encode history: %s", err)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		w.Write(b)
 	})
 }
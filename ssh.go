@@ -0,0 +1,600 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/creack/pty"
+	"golang.org/x/crypto/ssh"
+)
+
+const sshHostKeyFile = "ssh_host_ed25519_key"
+const sshAuthorizedKeysFile = "authorized_keys"
+
+// start sftp-server installed in the container
+const sftpServerScript = `for p in /usr/lib/openssh/sftp-server /usr/lib/ssh/sftp-server /usr/libexec/openssh/sftp-server /usr/libexec/sftp-server /usr/lib/sftp-server; do
+	if [ -x "$p" ]; then exec "$p"; fi
+done
+if command -v sftp-server >/dev/null 2>&1; then exec sftp-server; fi
+echo "sftp-server is not installed in the container" >&2
+exit 127`
+
+// relay stdin/stdout to TCP host ($0) and port ($1) with a tool found in the container
+const tcpRelayScript = `if command -v nc >/dev/null 2>&1; then exec nc "$0" "$1"; fi
+if command -v socat >/dev/null 2>&1; then exec socat - "TCP:$0:$1"; fi
+if command -v bash >/dev/null 2>&1; then exec bash -c 'exec 3<>"/dev/tcp/$0/$1" && { cat <&3 & cat >&3; }' "$0" "$1"; fi
+echo "nc, socat or bash is required in the container for port forwarding" >&2
+exit 127`
+
+// public key allowed to log in to the SSH gateway
+type authorizedKey struct {
+	Type        string
+	Fingerprint string
+	Comment     string
+	// line of authorized_keys
+	Key string
+}
+
+// authorized_keys in datadir. read on every login, so manual edits take effect immediately.
+type authorizedKeys struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newAuthorizedKeys(datadir string) *authorizedKeys {
+	return &authorizedKeys{path: path.Join(datadir, sshAuthorizedKeysFile)}
+}
+
+func parseAuthorizedKeyLine(line string) (authorizedKey, ssh.PublicKey, error) {
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return authorizedKey{}, nil, err
+	}
+	return authorizedKey{
+		Type:        pub.Type(),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		Comment:     comment,
+		Key:         strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))) + commentSuffix(comment),
+	}, pub, nil
+}
+
+func commentSuffix(comment string) string {
+	if comment == "" {
+		return ""
+	}
+	return " " + comment
+}
+
+// must be called with mu locked
+func (k *authorizedKeys) load() ([]authorizedKey, error) {
+	b, err := os.ReadFile(k.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error read `%s`: %s", k.path, err)
+	}
+
+	var keys []authorizedKey
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, err := parseAuthorizedKeyLine(line)
+		if err != nil {
+			log.Printf("skip invalid line of `%s`: %s", k.path, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// must be called with mu locked
+func (k *authorizedKeys) save(keys []authorizedKey) error {
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key.Key + "\n")
+	}
+
+	// unique temporary file, so a concurrent writer never sees a half written file
+	tmp, err := os.CreateTemp(path.Dir(k.path), "."+sshAuthorizedKeysFile+".*")
+	if err != nil {
+		return fmt.Errorf("error write `%s`: %s", k.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		return fmt.Errorf("error write `%s`: %s", k.path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error write `%s`: %s", k.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error write `%s`: %s", k.path, err)
+	}
+	if err := os.Rename(tmp.Name(), k.path); err != nil {
+		return fmt.Errorf("error rename `%s`: %s", tmp.Name(), err)
+	}
+	return nil
+}
+
+func (k *authorizedKeys) list() ([]authorizedKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.load()
+}
+
+// add a line of authorized_keys format (`ssh-ed25519 AAAA... comment`)
+func (k *authorizedKeys) add(line string) (authorizedKey, error) {
+	key, _, err := parseAuthorizedKeyLine(line)
+	if err != nil {
+		return authorizedKey{}, statusErrorf(http.StatusBadRequest, "error invalid public key: %s", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load()
+	if err != nil {
+		return authorizedKey{}, err
+	}
+	for _, existing := range keys {
+		if existing.Fingerprint == key.Fingerprint {
+			return authorizedKey{}, statusErrorf(http.StatusConflict, "error key `%s` is already authorized", key.Fingerprint)
+		}
+	}
+
+	return key, k.save(append(keys, key))
+}
+
+func (k *authorizedKeys) remove(fingerprint string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys, err := k.load()
+	if err != nil {
+		return err
+	}
+	n := len(keys)
+	keys = slices.DeleteFunc(keys, func(key authorizedKey) bool { return key.Fingerprint == fingerprint })
+	if len(keys) == n {
+		return statusErrorf(http.StatusNotFound, "error key `%s` not found", fingerprint)
+	}
+	return k.save(keys)
+}
+
+func (k *authorizedKeys) contains(pub ssh.PublicKey) bool {
+	keys, err := k.list()
+	if err != nil {
+		log.Printf("error load authorized keys: %s", err)
+		return false
+	}
+	fp := ssh.FingerprintSHA256(pub)
+	for _, key := range keys {
+		if key.Fingerprint == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// read ed25519 host key from datadir. creates it if not exists.
+func loadOrCreateHostKey(datadir string) (ssh.Signer, error) {
+	keypath := path.Join(datadir, sshHostKeyFile)
+	b, err := os.ReadFile(keypath)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("error parse host key `%s`: %s", keypath, err)
+		}
+		return signer, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error read host key `%s`: %s", keypath, err)
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generate host key: %s", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "devco")
+	if err != nil {
+		return nil, fmt.Errorf("error encode host key: %s", err)
+	}
+	if err := os.WriteFile(keypath, pem.EncodeToMemory(block), 0o600); err != nil {
+		return nil, fmt.Errorf("error write host key `%s`: %s", keypath, err)
+	}
+	log.Printf("generated SSH host key `%s`", keypath)
+
+	return ssh.NewSignerFromKey(priv)
+}
+
+// find the workspace of SSH user `{pjname}.{wsname}`.
+// names can contain `.`, so every workspace is compared with the whole user.
+func resolveSSHUser(user string, js projectsJson) (string, string, error) {
+	var pjname, wsname string
+	found := 0
+	for pn, p := range js {
+		for wn := range p.Workspaces {
+			if pn+"."+wn == user {
+				pjname, wsname = pn, wn
+				found++
+			}
+		}
+	}
+
+	switch found {
+	case 0:
+		return "", "", fmt.Errorf("workspace for user `%s` not found (use `{project}.{workspace}`)", user)
+	case 1:
+		return pjname, wsname, nil
+	default:
+		return "", "", fmt.Errorf("user `%s` matches multiple workspaces", user)
+	}
+}
+
+// SSH server that runs sessions, SFTP and port forwards in workspace containers.
+// the user name selects the workspace: `ssh {pjname}.{wsname}@host`.
+// only local port forwards (`ssh -L`) are supported. remote forwards (`ssh -R`) are rejected.
+type sshGateway struct {
+	st      projectStore
+	keys    *authorizedKeys
+	hostKey ssh.Signer
+	config  *ssh.ServerConfig
+}
+
+func newSSHGateway(datadir string, st projectStore, keys *authorizedKeys) (*sshGateway, error) {
+	hostKey, err := loadOrCreateHostKey(datadir)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &sshGateway{st: st, keys: keys, hostKey: hostKey}
+	g.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, pub ssh.PublicKey) (*ssh.Permissions, error) {
+			if !keys.contains(pub) {
+				return nil, fmt.Errorf("key `%s` is not authorized", ssh.FingerprintSHA256(pub))
+			}
+
+			js, err := st.Load()
+			if err != nil {
+				return nil, err
+			}
+			pjname, wsname, err := resolveSSHUser(meta.User(), js)
+			if err != nil {
+				return nil, err
+			}
+
+			return &ssh.Permissions{Extensions: map[string]string{"pjname": pjname, "wsname": wsname}}, nil
+		},
+	}
+	g.config.AddHostKey(hostKey)
+	return g, nil
+}
+
+// accept connections on addr until ctx is done
+func (g *sshGateway) serve(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	log.Printf("SSH gateway started on %s (host key %s)", addr, ssh.FingerprintSHA256(g.hostKey.PublicKey()))
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go g.handleConn(ctx, c)
+	}
+}
+
+func (g *sshGateway) handleConn(ctx context.Context, c net.Conn) {
+	conn, chans, reqs, err := ssh.NewServerConn(c, g.config)
+	if err != nil {
+		log.Printf("SSH handshake with %s failed: %s", c.RemoteAddr(), err)
+		c.Close()
+		return
+	}
+	defer conn.Close()
+
+	// processes in the container are killed when the connection is closed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	pjname := conn.Permissions.Extensions["pjname"]
+	wsname := conn.Permissions.Extensions["wsname"]
+	log.Printf("SSH connection from %s to workspace `%s` in project `%s`", conn.RemoteAddr(), wsname, pjname)
+
+	go rejectGlobalRequests(conn, reqs)
+
+	for nc := range chans {
+		ws, err := g.runningWorkspace(pjname, wsname)
+		if err != nil {
+			nc.Reject(ssh.Prohibited, err.Error())
+			continue
+		}
+
+		switch nc.ChannelType() {
+		case "session":
+			go g.handleSession(ctx, nc, ws)
+		case "direct-tcpip":
+			go g.handleDirectTCPIP(ctx, nc, ws)
+		default:
+			nc.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// reply false to global requests.
+// remote port forward (`ssh -R`, tcpip-forward) is not supported,
+// since a listener in the container would need a relay process kept running in it.
+func rejectGlobalRequests(conn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		if req.Type == "tcpip-forward" {
+			log.Printf("SSH remote port forward from %s rejected: not supported", conn.RemoteAddr())
+		}
+		if req.WantReply {
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (g *sshGateway) runningWorkspace(pjname string, wsname string) (projectsJsonWorkspace, error) {
+	js, err := g.st.Load()
+	if err != nil {
+		return projectsJsonWorkspace{}, err
+	}
+	ws, ok := js[pjname].Workspaces[wsname]
+	if !ok {
+		return projectsJsonWorkspace{}, fmt.Errorf("workspace `%s` not exists in project `%s`", wsname, pjname)
+	}
+	if ws.State != stateRunning || ws.ContainerId == "" {
+		return projectsJsonWorkspace{}, fmt.Errorf("workspace `%s` is not running", wsname)
+	}
+	return ws, nil
+}
+
+// `docker exec` (or `podman exec`) in the workspace container as RemoteUser
+func sshExecCommand(ctx context.Context, ws projectsJsonWorkspace, tty bool, env []string, args ...string) *exec.Cmd {
+	a := []string{"exec", "-i"}
+	if tty {
+		a = append(a, "-t")
+	}
+	if ws.RemoteUser != "" {
+		a = append(a, "-u", ws.RemoteUser)
+	}
+	if ws.RemoteWorkspaceFolder != "" {
+		a = append(a, "-w", ws.RemoteWorkspaceFolder)
+	}
+	for _, e := range env {
+		a = append(a, "-e", e)
+	}
+	a = append(a, ws.ContainerId)
+	return runtimeCommand(ctx, append(a, args...)...)
+}
+
+func (g *sshGateway) handleSession(ctx context.Context, nc ssh.NewChannel, ws projectsJsonWorkspace) {
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		log.Printf("error accept SSH session: %s", err)
+		return
+	}
+	defer ch.Close()
+
+	// kill the process when the session is closed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var env []string
+	var term string
+	var size *pty.Winsize
+	var tty *os.File
+	started := false
+	done := make(chan struct{})
+
+	for {
+		var req *ssh.Request
+		select {
+		case req = <-reqs:
+		case <-done:
+			return
+		}
+		if req == nil {
+			return
+		}
+
+		ok := false
+		switch req.Type {
+		case "env":
+			var p struct{ Name, Value string }
+			if ssh.Unmarshal(req.Payload, &p) == nil {
+				env = append(env, p.Name+"="+p.Value)
+				ok = true
+			}
+		case "pty-req":
+			var p struct {
+				Term                 string
+				Columns, Rows, Width uint32
+				Height               uint32
+				Modes                string
+			}
+			if ssh.Unmarshal(req.Payload, &p) == nil {
+				term = p.Term
+				size = &pty.Winsize{Cols: uint16(p.Columns), Rows: uint16(p.Rows)}
+				ok = true
+			}
+		case "window-change":
+			var p struct{ Columns, Rows, Width, Height uint32 }
+			if ssh.Unmarshal(req.Payload, &p) == nil && tty != nil {
+				pty.Setsize(tty, &pty.Winsize{Cols: uint16(p.Columns), Rows: uint16(p.Rows)})
+				ok = true
+			}
+		case "shell", "exec", "subsystem":
+			if started {
+				break
+			}
+
+			var args []string
+			switch req.Type {
+			case "shell":
+				args = []string{"sh", "-c", terminalShell}
+			case "exec":
+				var p struct{ Command string }
+				if ssh.Unmarshal(req.Payload, &p) != nil {
+					break
+				}
+				args = []string{"sh", "-c", p.Command}
+			case "subsystem":
+				var p struct{ Name string }
+				if ssh.Unmarshal(req.Payload, &p) != nil || p.Name != "sftp" {
+					break
+				}
+				args = []string{"sh", "-c", sftpServerScript}
+			}
+			if args == nil {
+				break
+			}
+
+			e := env
+			if size != nil {
+				if size.Cols == 0 || size.Rows == 0 {
+					size.Cols, size.Rows = 80, 24
+				}
+				e = append(e, "TERM="+term)
+			}
+			cmd := sshExecCommand(ctx, ws, size != nil, e, args...)
+
+			var err error
+			tty, err = startSSHCommand(cmd, ch, size)
+			if err != nil {
+				log.Printf("error start `%s` in container: %s", req.Type, err)
+				break
+			}
+			started = true
+			ok = true
+
+			go func() {
+				defer close(done)
+				status := waitSSHCommand(cmd, ch, tty)
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			}()
+		}
+
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+// connect cmd to the channel. with size, cmd runs on a pty (returned).
+func startSSHCommand(cmd *exec.Cmd, ch ssh.Channel, size *pty.Winsize) (*os.File, error) {
+	if size != nil {
+		tty, err := pty.StartWithSize(cmd, size)
+		if err != nil {
+			return nil, err
+		}
+		go io.Copy(tty, ch)
+		return tty, nil
+	}
+
+	// not cmd.Stdin = ch, because Wait would wait for the client to close stdin
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = ch
+	if cmd.Stderr == nil {
+		cmd.Stderr = ch.Stderr()
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		io.Copy(stdin, ch)
+		stdin.Close()
+	}()
+	return nil, nil
+}
+
+// wait for cmd and return exit status
+func waitSSHCommand(cmd *exec.Cmd, ch ssh.Channel, tty *os.File) uint32 {
+	if tty != nil {
+		// read until the process exits (EIO on linux)
+		io.Copy(ch, tty)
+		tty.Close()
+	}
+
+	err := cmd.Wait()
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return uint32(exitErr.ExitCode())
+	}
+	return 255
+}
+
+// local port forward (`ssh -L`). connects from inside the container,
+// so ports listening on localhost in the container can be forwarded.
+func (g *sshGateway) handleDirectTCPIP(ctx context.Context, nc ssh.NewChannel, ws projectsJsonWorkspace) {
+	var p struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(nc.ExtraData(), &p); err != nil {
+		nc.Reject(ssh.ConnectionFailed, "invalid direct-tcpip request")
+		return
+	}
+
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		log.Printf("error accept SSH direct-tcpip: %s", err)
+		return
+	}
+	defer ch.Close()
+	go ssh.DiscardRequests(reqs)
+
+	var stderr strings.Builder
+	cmd := sshExecCommand(ctx, ws, false, nil, "sh", "-c", tcpRelayScript, p.Host, fmt.Sprint(p.Port))
+	cmd.Stderr = &stderr
+	if _, err := startSSHCommand(cmd, ch, nil); err != nil {
+		log.Printf("error forward to %s:%d: %s", p.Host, p.Port, err)
+		return
+	}
+
+	if status := waitSSHCommand(cmd, ch, nil); status != 0 {
+		log.Printf("forward to %s:%d closed with status %d: %s", p.Host, p.Port, status, strings.TrimSpace(stderr.String()))
+	}
+}
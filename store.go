@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// persistence of projects and workspaces.
+// implementations must be safe for concurrent use.
+type projectStore interface {
+	// snapshot of all projects. changes to it are not saved (use Update).
+	Load() (projectsJson, error)
+	// load all projects, apply fn and save the result atomically.
+	// nothing is saved if fn returns error.
+	// returns struct{} to be used with jsonHelper.
+	Update(fn func(js projectsJson) error) (struct{}, error)
+	// state changes of the workspace, oldest first
+	History(pjname string, wsname string) ([]workspaceEvent, error)
+	Close() error
+}
+
+// state change of a workspace
+type workspaceEvent struct {
+	State       workspaceState
+	ContainerId string
+	IPAddress   string
+	Time        time.Time
+}
+
+var errHistoryUnsupported = errors.New("workspace history is not supported by this store")
+
+const (
+	storeJson   = "json"
+	storeSqlite = "sqlite"
+)
+
+func openProjectStore(datadir string, kind string) (projectStore, error) {
+	switch kind {
+	case "", storeJson:
+		return newJsonStore(datadir), nil
+	case storeSqlite:
+		return openSqliteStore(datadir)
+	default:
+		return nil, fmt.Errorf("unknown store `%s`", kind)
+	}
+}
+
+// open the store without writing to it, for inspecting the datadir of a running devco.
+// Update of the returned store fails (sqlite) or must not be called (json).
+func openProjectStoreReadOnly(datadir string, kind string) (projectStore, error) {
+	switch kind {
+	case "", storeJson:
+		return newJsonStore(datadir), nil
+	case storeSqlite:
+		return openSqliteStoreReadOnly(datadir)
+	default:
+		return nil, fmt.Errorf("unknown store `%s`", kind)
+	}
+}
+
+// Update on one workspace
+func updateWorkspace(st projectStore, pjname string, wsname string, fn func(ws *projectsJsonWorkspace) error) (struct{}, error) {
+	return st.Update(func(js projectsJson) error {
+		if _, ok := js[pjname]; !ok {
+			return statusErrorf(http.StatusNotFound, "error project `%s` not exists", pjname)
+		}
+
+		ws, ok := js[pjname].Workspaces[wsname]
+		if !ok {
+			return statusErrorf(http.StatusNotFound, "error workspace `%s` not exists in project `%s`", wsname, pjname)
+		}
+
+		if err := fn(&ws); err != nil {
+			return err
+		}
+		js[pjname].Workspaces[wsname] = ws
+		return nil
+	})
+}
+
+// concurrency-safe projects.json.
+// writes are atomic (temp file + rename) so a crash never leaves a broken file.
+type jsonStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newJsonStore(datadir string) *jsonStore {
+	return &jsonStore{path: path.Join(datadir, "projects.json")}
+}
+
+func (s *jsonStore) Load() (projectsJson, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+func (s *jsonStore) Update(fn func(js projectsJson) error) (struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	js, err := s.read()
+	if err != nil {
+		return struct{}{}, err
+	}
+
+	if err := fn(js); err != nil {
+		return struct{}{}, err
+	}
+
+	return struct{}{}, s.write(js)
+}
+
+// projects.json keeps only the current state
+func (s *jsonStore) History(pjname string, wsname string) ([]workspaceEvent, error) {
+	return nil, errHistoryUnsupported
+}
+
+func (s *jsonStore) Close() error {
+	return nil
+}
+
+func (s *jsonStore) readRaw() ([]byte, error) {
+	file, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error read projects.json: %s", err)
+	}
+	return file, nil
+}
+
+func (s *jsonStore) read() (projectsJson, error) {
+	file, err := s.readRaw()
+	if err != nil {
+		return projectsJson{}, err
+	}
+
+	var j projectsJson
+	err = json.Unmarshal(file, &j)
+	if err != nil {
+		return projectsJson{}, fmt.Errorf("error decode projects.json: %s", err)
+	}
+	if j == nil {
+		j = projectsJson{}
+	}
+
+	return j, nil
+}
+
+func (s *jsonStore) write(data projectsJson) error {
+	j, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encode projects.json: %s", err)
+	}
+
+	tmp, err := os.CreateTemp(path.Dir(s.path), ".projects.json.*")
+	if err != nil {
+		return fmt.Errorf("error write projects.json: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(j); err != nil {
+		return fmt.Errorf("error write projects.json: %s", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error write projects.json: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error write projects.json: %s", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("error write projects.json: %s", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error write projects.json: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// schema migrations. migrations[i] upgrades the database from version i to i+1.
+// the current version is kept in `PRAGMA user_version`.
+// never edit an existing migration, append a new one.
+var sqliteMigrations = []string{
+	`
+	CREATE TABLE projects (
+		name TEXT PRIMARY KEY,
+		path TEXT NOT NULL
+	);
+
+	-- data is the whole projectsJsonWorkspace as JSON.
+	-- other columns are copies of it for queries.
+	CREATE TABLE workspaces (
+		project      TEXT NOT NULL REFERENCES projects(name) ON DELETE CASCADE,
+		name         TEXT NOT NULL,
+		state        TEXT NOT NULL,
+		branch_name  TEXT NOT NULL,
+		path         TEXT NOT NULL,
+		container_id TEXT NOT NULL,
+		ip_address   TEXT NOT NULL,
+		data         TEXT NOT NULL,
+		created_at   TIMESTAMP NOT NULL,
+		updated_at   TIMESTAMP NOT NULL,
+		PRIMARY KEY (project, name)
+	);
+
+	-- every state change of workspaces. kept after the workspace is removed.
+	CREATE TABLE workspace_events (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		project      TEXT NOT NULL,
+		workspace    TEXT NOT NULL,
+		state        TEXT NOT NULL,
+		container_id TEXT NOT NULL,
+		ip_address   TEXT NOT NULL,
+		at           TIMESTAMP NOT NULL
+	);
+	CREATE INDEX workspace_events_workspace ON workspace_events (project, workspace, id);
+
+	CREATE TABLE meta (
+		key   TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	`,
+	`
+	-- projectsJsonProject.Mounts as JSON
+	ALTER TABLE projects ADD COLUMN mounts TEXT NOT NULL DEFAULT 'null';
+	`,
+}
+
+// marks that projects.json is already imported
+const metaProjectsJsonImported = "projects_json_imported"
+
+// projects and workspaces in SQLite (datadir/devco.db)
+type sqliteStore struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func openSqliteStore(datadir string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path.Join(datadir, "devco.db")+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("error open database: %s", err)
+	}
+	// serialize all access in the process, same as jsonStore
+	db.SetMaxOpenConns(1)
+
+	s := &sqliteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := s.importProjectsJson(path.Join(datadir, "projects.json")); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// open devco.db without migration and import of projects.json.
+// the schema must be already migrated to the version of this devco.
+func openSqliteStoreReadOnly(datadir string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path.Join(datadir, "devco.db")+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("error open database: %s", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error get schema version: %s", err)
+	}
+	if version != len(sqliteMigrations) {
+		db.Close()
+		return nil, fmt.Errorf("database schema version %d differs from this devco (%d)", version, len(sqliteMigrations))
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("error get schema version: %s", err)
+	}
+
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this devco (%d)", version, len(sqliteMigrations))
+	}
+
+	for v := version; v < len(sqliteMigrations); v++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("error migrate database: %s", err)
+		}
+
+		if _, err := tx.Exec(sqliteMigrations[v]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error migrate database to version %d: %s", v+1, err)
+		}
+		// PRAGMA does not accept placeholders
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error migrate database to version %d: %s", v+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error migrate database to version %d: %s", v+1, err)
+		}
+		log.Printf("database migrated to version %d", v+1)
+	}
+
+	return nil
+}
+
+// import existing projects.json once. projects.json itself is left as is.
+func (s *sqliteStore) importProjectsJson(pjpath string) error {
+	var v string
+	err := s.db.QueryRow("SELECT value FROM meta WHERE key = ?", metaProjectsJsonImported).Scan(&v)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error read meta: %s", err)
+	}
+
+	var js projectsJson
+	file, err := os.ReadFile(pjpath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(file, &js); err != nil {
+			return fmt.Errorf("error decode projects.json: %s", err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return fmt.Errorf("error read projects.json: %s", err)
+	}
+
+	_, err = s.Update(func(current projectsJson) error {
+		for pn, p := range js {
+			if _, ok := current[pn]; ok {
+				continue
+			}
+			current[pn] = p
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error import projects.json: %s", err)
+	}
+
+	_, err = s.db.Exec("INSERT INTO meta (key, value) VALUES (?, ?)", metaProjectsJsonImported, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error write meta: %s", err)
+	}
+
+	if len(js) > 0 {
+		log.Printf("imported %d projects from projects.json", len(js))
+	}
+	return nil
+}
+
+func (s *sqliteStore) Load() (projectsJson, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(s.db)
+}
+
+type sqlQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func (s *sqliteStore) load(q sqlQuerier) (projectsJson, error) {
+	js := projectsJson{}
+
+	rows, err := q.Query("SELECT name, path, mounts FROM projects")
+	if err != nil {
+		return nil, fmt.Errorf("error read projects: %s", err)
+	}
+	for rows.Next() {
+		var name, p, mounts string
+		if err := rows.Scan(&name, &p, &mounts); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error read projects: %s", err)
+		}
+
+		var ms []mount
+		if err := json.Unmarshal([]byte(mounts), &ms); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error decode mounts of project `%s`: %s", name, err)
+		}
+		js[name] = projectsJsonProject{Path: p, Mounts: ms, Workspaces: map[string]projectsJsonWorkspace{}}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error read projects: %s", err)
+	}
+
+	rows, err = q.Query("SELECT project, name, data FROM workspaces")
+	if err != nil {
+		return nil, fmt.Errorf("error read workspaces: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pn, wn, data string
+		if err := rows.Scan(&pn, &wn, &data); err != nil {
+			return nil, fmt.Errorf("error read workspaces: %s", err)
+		}
+
+		var ws projectsJsonWorkspace
+		if err := json.Unmarshal([]byte(data), &ws); err != nil {
+			return nil, fmt.Errorf("error decode workspace `%s` in project `%s`: %s", wn, pn, err)
+		}
+		js[pn].Workspaces[wn] = ws
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error read workspaces: %s", err)
+	}
+
+	return js, nil
+}
+
+func (s *sqliteStore) Update(fn func(js projectsJson) error) (struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return struct{}{}, fmt.Errorf("error begin transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.load(tx)
+	if err != nil {
+		return struct{}{}, err
+	}
+	js, err := s.load(tx)
+	if err != nil {
+		return struct{}{}, err
+	}
+
+	if err := fn(js); err != nil {
+		return struct{}{}, err
+	}
+
+	if err := s.save(tx, before, js); err != nil {
+		return struct{}{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return struct{}{}, fmt.Errorf("error commit transaction: %s", err)
+	}
+	return struct{}{}, nil
+}
+
+// write the difference between before and after
+func (s *sqliteStore) save(tx *sql.Tx, before projectsJson, after projectsJson) error {
+	now := time.Now().UTC()
+
+	for pn := range before {
+		if _, ok := after[pn]; ok {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM projects WHERE name = ?", pn); err != nil {
+			return fmt.Errorf("error delete project `%s`: %s", pn, err)
+		}
+	}
+
+	for pn, p := range after {
+		old, existed := before[pn]
+		if !existed || old.Path != p.Path || !slices.Equal(old.Mounts, p.Mounts) {
+			mounts, err := json.Marshal(p.Mounts)
+			if err != nil {
+				return fmt.Errorf("error encode mounts of project `%s`: %s", pn, err)
+			}
+			_, err = tx.Exec("INSERT INTO projects (name, path, mounts) VALUES (?, ?, ?) ON CONFLICT (name) DO UPDATE SET path = excluded.path, mounts = excluded.mounts", pn, p.Path, string(mounts))
+			if err != nil {
+				return fmt.Errorf("error write project `%s`: %s", pn, err)
+			}
+		}
+
+		for wn := range old.Workspaces {
+			if _, ok := p.Workspaces[wn]; ok {
+				continue
+			}
+			if _, err := tx.Exec("DELETE FROM workspaces WHERE project = ? AND name = ?", pn, wn); err != nil {
+				return fmt.Errorf("error delete workspace `%s` in project `%s`: %s", wn, pn, err)
+			}
+		}
+
+		for wn, ws := range p.Workspaces {
+			data, err := json.Marshal(ws)
+			if err != nil {
+				return fmt.Errorf("error encode workspace `%s` in project `%s`: %s", wn, pn, err)
+			}
+
+			oldws, wsExisted := old.Workspaces[wn]
+			if wsExisted {
+				olddata, err := json.Marshal(oldws)
+				if err == nil && string(olddata) == string(data) {
+					continue
+				}
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO workspaces (project, name, state, branch_name, path, container_id, ip_address, data, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (project, name) DO UPDATE SET
+					state = excluded.state,
+					branch_name = excluded.branch_name,
+					path = excluded.path,
+					container_id = excluded.container_id,
+					ip_address = excluded.ip_address,
+					data = excluded.data,
+					updated_at = excluded.updated_at`,
+				pn, wn, ws.State, ws.BranchName, ws.Path, ws.ContainerId, ws.IPAddress, string(data), now, now)
+			if err != nil {
+				return fmt.Errorf("error write workspace `%s` in project `%s`: %s", wn, pn, err)
+			}
+
+			if !wsExisted || oldws.State != ws.State {
+				_, err = tx.Exec("INSERT INTO workspace_events (project, workspace, state, container_id, ip_address, at) VALUES (?, ?, ?, ?, ?, ?)",
+					pn, wn, ws.State, ws.ContainerId, ws.IPAddress, now)
+				if err != nil {
+					return fmt.Errorf("error write history of workspace `%s` in project `%s`: %s", wn, pn, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) History(pjname string, wsname string) ([]workspaceEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows, err := s.db.Query("SELECT state, container_id, ip_address, at FROM workspace_events WHERE project = ? AND workspace = ? ORDER BY id", pjname, wsname)
+	if err != nil {
+		return nil, fmt.Errorf("error read history: %s", err)
+	}
+	defer rows.Close()
+
+	var events []workspaceEvent
+	for rows.Next() {
+		var e workspaceEvent
+		if err := rows.Scan(&e.State, &e.ContainerId, &e.IPAddress, &e.Time); err != nil {
+			return nil, fmt.Errorf("error read history: %s", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error read history: %s", err)
+	}
+
+	return events, nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	caValidity     = 10 * 365 * 24 * time.Hour
+	serverValidity = 397 * 24 * time.Hour
+	// regenerate server certificate before it expires
+	serverRenewBefore = 30 * 24 * time.Hour
+)
+
+// TLS config of the server. nil means plain HTTP.
+func loadTLSConfig(datadir string, c tlsConfig) (*tls.Config, error) {
+	switch {
+	case c.Cert != "" || c.Key != "":
+		if c.Cert == "" || c.Key == "" {
+			return nil, errors.New("both TLS.Cert and TLS.Key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error load certificate: %s", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	case c.Auto:
+		cert, err := autoCertificate(path.Join(datadir, "tls"), tlsHosts(c.Hosts))
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// configured hosts and the names of this machine
+func tlsHosts(configured []string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name)
+	}
+	for _, h := range configured {
+		if !slices.Contains(hosts, h) {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// local CA and server certificate signed by it, kept in dir.
+// the CA is created once. the server certificate is created again
+// when it expires soon or does not cover hosts.
+func autoCertificate(dir string, hosts []string) (tls.Certificate, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("error make directory `%s`: %s", dir, err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(path.Join(dir, "ca.pem"), path.Join(dir, "ca-key.pem"))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPath := path.Join(dir, "server.pem")
+	keyPath := path.Join(dir, "server-key.pem")
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil && serverCertValid(cert, caCert, hosts) {
+		return cert, nil
+	}
+
+	log.Printf("generate TLS server certificate for %v", hosts)
+	if err := createServerCert(certPath, keyPath, caCert, caKey, hosts); err != nil {
+		return tls.Certificate{}, err
+	}
+	log.Printf("TLS certificate is signed by local CA `%s`. trust it in your browser or OS", path.Join(dir, "ca.pem"))
+
+	cert, err = tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error load certificate: %s", err)
+	}
+	return cert, nil
+}
+
+func serverCertValid(cert tls.Certificate, ca *x509.Certificate, hosts []string) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false
+	}
+	if time.Now().Add(serverRenewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	if leaf.CheckSignatureFrom(ca) != nil {
+		return false
+	}
+	for _, h := range hosts {
+		// VerifyHostname does not accept wildcard names
+		if strings.HasPrefix(h, "*.") {
+			if !slices.Contains(leaf.DNSNames, h) {
+				return false
+			}
+			continue
+		}
+		if leaf.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func loadOrCreateCA(certPath string, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil {
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parse CA certificate: %s", err)
+		}
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, nil, errors.New("CA key is not ECDSA")
+		}
+		return cert, key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("error load CA: %s", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generate CA key: %s", err)
+	}
+
+	hostname, _ := os.Hostname()
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{Organization: []string{"devco"}, CommonName: fmt.Sprintf("devco local CA (%s)", hostname)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error create CA certificate: %s", err)
+	}
+	if err := writePEM(certPath, keyPath, der, key); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parse CA certificate: %s", err)
+	}
+	log.Printf("created local CA `%s`", certPath)
+	return cert, key, nil
+}
+
+func createServerCert(certPath string, keyPath string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("error generate server key: %s", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{Organization: []string{"devco"}, CommonName: hosts[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(serverValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("error create server certificate: %s", err)
+	}
+	return writePEM(certPath, keyPath, der, key)
+}
+
+func writePEM(certPath string, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("error encode key: %s", err)
+	}
+
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	if err != nil {
+		return fmt.Errorf("error write key `%s`: %s", keyPath, err)
+	}
+
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	if err != nil {
+		return fmt.Errorf("error write certificate `%s`: %s", certPath, err)
+	}
+	return nil
+}
+
+func randomSerial() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(fmt.Sprintf("internal random error: %s", err))
+	}
+	return n
+}
+
+// download the local CA to trust it (only in auto mode)
+func serveTLSAPI(datadir string, c tlsConfig) {
+	if !c.Auto || c.Cert != "" {
+		return
+	}
+
+	http.HandleFunc("GET /tls/ca.pem", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		http.ServeFile(w, r, path.Join(datadir, "tls", "ca.pem"))
+	})
+}
@@ -11,25 +11,37 @@ import {
   useParams,
 } from "react-router-dom";
 import {
+  cancelWorkspaceLaunch,
   createProject,
   createWorkspace,
   deleteProject,
   deleteWorkspace,
   downWorkspaceContainer,
   fetchConfig,
+  fetchJob,
   fetchProjects,
   fetchWorkspaceOpenLinks,
   launchWorkspaceContainer,
+  rebuildWorkspaceContainer,
+  waitForJob,
 } from "./lib/api";
+import { getJobErrorDetail, getJobErrorMessage } from "./lib/jobs";
 import { findProject, findWorkspace, toProjectList, toWorkspaceList } from "./lib/project-data";
-import type { ProjectsMap, Workspace } from "./lib/types";
+import type { Job, ProjectsMap, UpErrorDetail, Workspace } from "./lib/types";
 import {
   getRenderableOpenLinks,
+  hasPendingOpenLinks,
   parseWorkspacePluginSelectionCookie,
   serializeWorkspacePluginSelectionCookie,
   type RenderableOpenLink,
 } from "./lib/workspace-launch";
 
+// how often open links are checked again while some ports are not ready
+const openLinkRetryInterval = 3000;
+
+// how often a running job is checked
+const jobPollInterval = 1000;
+
 function getErrorMessage(error: unknown): string {
   if (error instanceof Error) {
     return error.message;
@@ -62,6 +74,24 @@ function useProjectsData() {
   return { projects, loading, error, setError, reload };
 }
 
+// what failed in `devcontainer up` (like a feature or a Dockerfile step)
+function UpErrorPanel({ detail }: { detail: UpErrorDetail | null }) {
+  if (!detail) {
+    return null;
+  }
+  return (
+    <div className="space-y-2 rounded-md border border-red-200 bg-red-50 px-3 py-2 text-sm text-red-700">
+      {detail.Description && <p className="font-medium">{detail.Description}</p>}
+      {detail.Message && <p className="break-all">{detail.Message}</p>}
+      {detail.Stderr && detail.Stderr.length > 0 && (
+        <pre className="max-h-64 overflow-auto whitespace-pre-wrap rounded bg-white p-2 text-xs text-slate-800">
+          {detail.Stderr.join("\n")}
+        </pre>
+      )}
+    </div>
+  );
+}
+
 function ErrorBanner({ message }: { message: string | null }) {
   if (!message) {
     return null;
@@ -350,8 +380,12 @@ function ProjectDetailPage() {
     }
     setSubmitting(true);
     try {
-      await deleteWorkspace({ projectName, workspaceName: targetName });
+      const job = await waitForJob(await deleteWorkspace({ projectName, workspaceName: targetName }), jobPollInterval);
       await reload();
+      const message = getJobErrorMessage(job);
+      if (message) {
+        setError(message);
+      }
     } catch (error) {
       setError(getErrorMessage(error));
     } finally {
@@ -704,6 +738,8 @@ function WorkspaceDetailPage() {
   const [selectedPlugins, setSelectedPlugins] = useState<string[]>([]);
   const [loadingPlugins, setLoadingPlugins] = useState(false);
   const [openLinks, setOpenLinks] = useState<RenderableOpenLink[]>([]);
+  // latest job started from this page
+  const [job, setJob] = useState<Job | null>(null);
   const navigate = useNavigate();
   const workspace = findWorkspace(projects, projectName, workspaceName);
   const currentProjectName = projectName ?? "";
@@ -712,32 +748,41 @@ function WorkspaceDetailPage() {
 
   useEffect(() => {
     let ignore = false;
+    let retryTimer: number | undefined;
 
     if (!isRunning || !currentProjectName || !currentWorkspaceName) {
       setOpenLinks([]);
       return;
     }
 
-    void fetchWorkspaceOpenLinks({
-      projectName: currentProjectName,
-      workspaceName: currentWorkspaceName,
-    })
-      .then((links) => {
-        if (ignore) {
-          return;
-        }
-        setOpenLinks(getRenderableOpenLinks(window.location.origin, currentProjectName, currentWorkspaceName, links));
+    const loadOpenLinks = () => {
+      void fetchWorkspaceOpenLinks({
+        projectName: currentProjectName,
+        workspaceName: currentWorkspaceName,
       })
-      .catch((error) => {
-        if (ignore) {
-          return;
-        }
-        setOpenLinks([]);
-        setError(getErrorMessage(error));
-      });
+        .then((links) => {
+          if (ignore) {
+            return;
+          }
+          setOpenLinks(getRenderableOpenLinks(window.location.origin, currentProjectName, currentWorkspaceName, links));
+          // links are shown once their ports answer
+          if (hasPendingOpenLinks(links)) {
+            retryTimer = window.setTimeout(loadOpenLinks, openLinkRetryInterval);
+          }
+        })
+        .catch((error) => {
+          if (ignore) {
+            return;
+          }
+          setOpenLinks([]);
+          setError(getErrorMessage(error));
+        });
+    };
+    loadOpenLinks();
 
     return () => {
       ignore = true;
+      window.clearTimeout(retryTimer);
     };
   }, [currentProjectName, currentWorkspaceName, isRunning, setError]);
 
@@ -781,6 +826,45 @@ function WorkspaceDetailPage() {
     };
   }, [currentProjectName, currentWorkspaceName, isLaunchDialogOpen, setError]);
 
+  // follow the running job, and show its error when it failed or was canceled
+  useEffect(() => {
+    if (!job || job.Status !== "running") {
+      return;
+    }
+
+    let ignore = false;
+    const timer = window.setTimeout(() => {
+      void fetchJob(job.Id)
+        .then((next) => {
+          if (ignore) {
+            return;
+          }
+          setJob(next);
+          if (next.Status === "running") {
+            return;
+          }
+          const message = getJobErrorMessage(next);
+          void reload().then(() => {
+            if (message) {
+              setError(message);
+            }
+          });
+        })
+        .catch((error) => {
+          if (ignore) {
+            return;
+          }
+          setJob(null);
+          setError(getErrorMessage(error));
+        });
+    }, jobPollInterval);
+
+    return () => {
+      ignore = true;
+      window.clearTimeout(timer);
+    };
+  }, [job, reload, setError]);
+
   async function withAction(action: () => Promise<void>) {
     setSubmitting(true);
     try {
@@ -793,6 +877,16 @@ function WorkspaceDetailPage() {
     }
   }
 
+  // start a job and follow it in background. returns whether it was started
+  async function withJob(start: () => Promise<string>): Promise<boolean> {
+    let started = false;
+    await withAction(async () => {
+      setJob(await fetchJob(await start()));
+      started = true;
+    });
+    return started;
+  }
+
   function togglePlugin(pluginName: string) {
     setSelectedPlugins((current) => {
       const next = current.includes(pluginName)
@@ -832,9 +926,7 @@ function WorkspaceDetailPage() {
       return;
     }
 
-    await withAction(async () => {
-      await downWorkspaceContainer({ projectName: currentProjectName, workspaceName: currentWorkspaceName });
-    });
+    await withJob(() => downWorkspaceContainer({ projectName: currentProjectName, workspaceName: currentWorkspaceName }));
   }
 
   async function onLaunchWorkspace(event: FormEvent<HTMLFormElement>) {
@@ -843,25 +935,37 @@ function WorkspaceDetailPage() {
     const launchPlugins = selectedPlugins.filter((pluginName) => availablePlugins.includes(pluginName));
     document.cookie = serializeWorkspacePluginSelectionCookie(currentProjectName, currentWorkspaceName, launchPlugins);
 
-    let launched = false;
-    await withAction(async () => {
-      await launchWorkspaceContainer({
+    const launched = await withJob(() =>
+      launchWorkspaceContainer({
         projectName: currentProjectName,
         workspaceName: currentWorkspaceName,
         plugins: launchPlugins,
-      });
-      launched = true;
-    });
+      }),
+    );
 
     if (launched) {
       setLaunchDialogOpen(false);
     }
   }
 
+  async function onCancelLaunch() {
+    await withAction(async () => {
+      await cancelWorkspaceLaunch({ projectName: currentProjectName, workspaceName: currentWorkspaceName });
+    });
+  }
+
+  async function onRebuildWorkspace() {
+    await withJob(() => rebuildWorkspaceContainer({ projectName: currentProjectName, workspaceName: currentWorkspaceName }));
+  }
+
   async function onRemoveWorkspace() {
     setRemoveDialogOpen(false);
     await withAction(async () => {
-      await deleteWorkspace({ projectName: currentProjectName, workspaceName: currentWorkspaceName });
+      const jobId = await deleteWorkspace({ projectName: currentProjectName, workspaceName: currentWorkspaceName });
+      const message = getJobErrorMessage(await waitForJob(jobId, jobPollInterval));
+      if (message) {
+        throw new Error(message);
+      }
       navigate(`/projects/${currentProjectName}`);
     });
   }
@@ -879,6 +983,7 @@ function WorkspaceDetailPage() {
         </button>
       </div>
       <ErrorBanner message={error} />
+      <UpErrorPanel detail={job ? getJobErrorDetail(job) : null} />
       <Modal onClose={() => setRemoveDialogOpen(false)} open={isRemoveDialogOpen} title="Remove workspace">
         <p className="text-sm text-slate-700">Are you sure you want to remove workspace "{currentWorkspaceName}"?</p>
         <div className="mt-4 flex justify-end gap-2">
@@ -965,6 +1070,26 @@ function WorkspaceDetailPage() {
           >
             {containerActionLabel}
           </button>
+          {workspace.State === "starting" && (
+            <button
+              className="rounded-md border border-amber-300 px-4 py-2 text-sm font-medium text-amber-700 hover:bg-amber-50 disabled:cursor-not-allowed disabled:opacity-50"
+              disabled={submitting}
+              onClick={() => void onCancelLaunch()}
+              type="button"
+            >
+              Cancel Launch
+            </button>
+          )}
+          {workspace.State !== "beforeStart" && workspace.State !== "starting" && (
+            <button
+              className="rounded-md border border-slate-300 px-4 py-2 text-sm font-medium text-slate-700 hover:bg-slate-100 disabled:cursor-not-allowed disabled:opacity-50"
+              disabled={submitting}
+              onClick={() => void onRebuildWorkspace()}
+              type="button"
+            >
+              Container Rebuild
+            </button>
+          )}
           {openLinks.map((link) => (
             <button
               className="rounded-md border border-slate-300 px-4 py-2 text-sm font-medium text-slate-700 hover:bg-slate-100 disabled:cursor-not-allowed disabled:opacity-50"
@@ -985,6 +1110,11 @@ function WorkspaceDetailPage() {
             Workspace Remove
           </button>
         </div>
+        {job?.Status === "running" && (
+          <p className="mt-3 text-sm text-slate-600">
+            {job.Kind}: {job.Progress || "waiting..."}
+          </p>
+        )}
       </SectionCard>
     </>
   );
@@ -1,4 +1,4 @@
-import type { AppConfig, ProjectsMap, WorkspaceOpenLinks } from "./types";
+import type { AppConfig, Job, ProjectsMap, WorkspaceOpenLinks } from "./types";
 
 type CreateProjectInput = {
   name: string;
@@ -20,14 +20,46 @@ type LaunchWorkspaceInput = WorkspaceActionInput & {
   plugins?: string[];
 };
 
+type RebuildWorkspaceInput = WorkspaceActionInput & {
+  noCache?: boolean;
+  expectExisting?: boolean;
+};
+
 async function ensureOk(res: Response): Promise<void> {
   if (res.ok) {
     return;
   }
+  if (res.status === 401) {
+    window.location.assign(`/login?next=${encodeURIComponent(window.location.pathname + window.location.search)}`);
+  }
   const message = await res.text();
   throw new Error(message || `request failed (${res.status})`);
 }
 
+// id of the job started by the request (`202 Accepted`)
+async function acceptedJobId(res: Response): Promise<string> {
+  await ensureOk(res);
+  const body = (await res.json()) as { JobId: string };
+  return body.JobId;
+}
+
+export async function fetchJob(jobId: string): Promise<Job> {
+  const res = await fetch(`/api/jobs/${encodeURIComponent(jobId)}`);
+  await ensureOk(res);
+  return (await res.json()) as Job;
+}
+
+// poll the job until it is finished
+export async function waitForJob(jobId: string, interval = 1000): Promise<Job> {
+  for (;;) {
+    const job = await fetchJob(jobId);
+    if (job.Status !== "running") {
+      return job;
+    }
+    await new Promise((resolve) => window.setTimeout(resolve, interval));
+  }
+}
+
 export async function fetchProjects(): Promise<ProjectsMap> {
   const res = await fetch("/api/project");
   await ensureOk(res);
@@ -75,13 +107,13 @@ export async function createWorkspace(input: CreateWorkspaceInput): Promise<void
   await ensureOk(res);
 }
 
-export async function deleteWorkspace(input: WorkspaceActionInput): Promise<void> {
+export async function deleteWorkspace(input: WorkspaceActionInput): Promise<string> {
   const url = `/api/workspace?pjname=${encodeURIComponent(input.projectName)}&wsname=${encodeURIComponent(input.workspaceName)}`;
   const res = await fetch(url, { method: "DELETE" });
-  await ensureOk(res);
+  return acceptedJobId(res);
 }
 
-export async function launchWorkspaceContainer(input: LaunchWorkspaceInput): Promise<void> {
+export async function launchWorkspaceContainer(input: LaunchWorkspaceInput): Promise<string> {
   const res = await fetch("/api/workspace/launch", {
     method: "POST",
     headers: { "Content-Type": "application/json" },
@@ -91,9 +123,35 @@ export async function launchWorkspaceContainer(input: LaunchWorkspaceInput): Pro
       Plugins: input.plugins ?? [],
     }),
   });
+  return acceptedJobId(res);
+}
+
+export async function cancelWorkspaceLaunch(input: WorkspaceActionInput): Promise<void> {
+  const res = await fetch("/api/workspace/launch/cancel", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      ProjectName: input.projectName,
+      WorkspaceName: input.workspaceName,
+    }),
+  });
   await ensureOk(res);
 }
 
+export async function rebuildWorkspaceContainer(input: RebuildWorkspaceInput): Promise<string> {
+  const res = await fetch("/api/workspace/rebuild", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      ProjectName: input.projectName,
+      WorkspaceName: input.workspaceName,
+      NoCache: input.noCache,
+      ExpectExisting: input.expectExisting ?? false,
+    }),
+  });
+  return acceptedJobId(res);
+}
+
 export async function fetchWorkspaceOpenLinks(input: WorkspaceActionInput): Promise<WorkspaceOpenLinks> {
   const url = `/api/workspace/openlink?pjname=${encodeURIComponent(input.projectName)}&wsname=${encodeURIComponent(input.workspaceName)}`;
   const res = await fetch(url);
@@ -101,7 +159,7 @@ export async function fetchWorkspaceOpenLinks(input: WorkspaceActionInput): Prom
   return ((await res.json()) as WorkspaceOpenLinks | null) ?? {};
 }
 
-export async function downWorkspaceContainer(input: WorkspaceActionInput): Promise<void> {
+export async function downWorkspaceContainer(input: WorkspaceActionInput): Promise<string> {
   const res = await fetch("/api/workspace/down", {
     method: "POST",
     headers: { "Content-Type": "application/json" },
@@ -110,5 +168,5 @@ export async function downWorkspaceContainer(input: WorkspaceActionInput): Promi
       WorkspaceName: input.workspaceName,
     }),
   });
-  await ensureOk(res);
+  return acceptedJobId(res);
 }
@@ -0,0 +1,46 @@
+import { describe, expect, it } from "vitest";
+import type { Job } from "./types";
+import { getJobErrorDetail, getJobErrorMessage } from "./jobs";
+
+function job(overrides: Partial<Job>): Job {
+  return {
+    Id: "1",
+    Kind: "launch",
+    ProjectName: "alpha",
+    WorkspaceName: "ws-a1",
+    Status: "running",
+    Progress: "",
+    Error: "",
+    ...overrides,
+  };
+}
+
+describe("jobs", () => {
+  it("has no error message while running or after success", () => {
+    expect(getJobErrorMessage(job({ Status: "running" }))).toBeNull();
+    expect(getJobErrorMessage(job({ Status: "succeeded" }))).toBeNull();
+  });
+
+  it("shows the error of failed and canceled jobs", () => {
+    expect(getJobErrorMessage(job({ Status: "failed", Error: "error launch container" }))).toBe("error launch container");
+    expect(getJobErrorMessage(job({ Status: "canceled", Error: "launch canceled" }))).toBe("launch canceled");
+    expect(getJobErrorMessage(job({ Status: "failed", Kind: "down" }))).toBe("down failed");
+  });
+
+  it("returns the devcontainer up failure of failed jobs", () => {
+    const detail = {
+      Outcome: "error",
+      Message: "Command failed: docker buildx build ...",
+      Description: "An error occurred building the image.",
+      ExitCode: 1,
+      Stderr: null,
+    };
+
+    expect(getJobErrorDetail(job({ Status: "failed", ErrorDetail: detail }))).toEqual({ ...detail, Stderr: [] });
+    expect(getJobErrorDetail(job({ Status: "succeeded", ErrorDetail: detail }))).toBeNull();
+    expect(getJobErrorDetail(job({ Status: "failed" }))).toBeNull();
+    expect(
+      getJobErrorDetail(job({ Status: "failed", ErrorDetail: { ...detail, Message: "", Description: "" } })),
+    ).toBeNull();
+  });
+});
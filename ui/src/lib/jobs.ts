@@ -0,0 +1,25 @@
+import type { Job, UpErrorDetail } from "./types";
+
+// message to show for a finished job. null when it succeeded or is still running
+export function getJobErrorMessage(job: Job): string | null {
+  switch (job.Status) {
+    case "failed":
+      return job.Error || `${job.Kind} failed`;
+    case "canceled":
+      return job.Error || `${job.Kind} canceled`;
+    default:
+      return null;
+  }
+}
+
+// structured error of a failed or canceled job, when the server has one
+export function getJobErrorDetail(job: Job): UpErrorDetail | null {
+  if (job.Status !== "failed" && job.Status !== "canceled") {
+    return null;
+  }
+  const detail = job.ErrorDetail;
+  if (!detail || (!detail.Message && !detail.Description && !detail.Stderr?.length)) {
+    return null;
+  }
+  return { ...detail, Stderr: detail.Stderr ?? [] };
+}
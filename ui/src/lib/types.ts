@@ -1,15 +1,36 @@
-export type WorkspaceState = "beforeStart" | "running" | "stopped";
+export type WorkspaceState = "beforeStart" | "starting" | "running" | "stopped" | "failed";
+
+export type LinkReadiness = "ready" | "notReady" | "unknown";
+
+export type LinkProbe = {
+  Type: "http" | "tcp" | "";
+  Path?: string;
+};
 
 export type WorkspaceOpenLink = {
   Port: number;
   Path: string;
+  Protocol?: string;
+  Probe?: LinkProbe;
+  Readiness?: LinkReadiness;
 };
 
 export type WorkspaceOpenLinks = Record<string, WorkspaceOpenLink>;
 
+export type PortVisibility = "private" | "shared" | "disabled";
+
+export type MountConfig = {
+  Type: "bind" | "volume" | "";
+  Source: string;
+  Target: string;
+  External?: boolean;
+};
+
 export type PluginConfig = {
   Features: Record<string, Record<string, unknown>>;
   Links: WorkspaceOpenLinks;
+  Mounts?: MountConfig[] | null;
+  PostStartCommands?: string[] | null;
 };
 
 export type AppConfig = {
@@ -25,12 +46,39 @@ export type Workspace = {
   RemoteUser: string;
   RemoteWorkspaceFolder: string;
   IPAddress: string;
+  Plugins?: string[] | null;
   OpenLinks?: WorkspaceOpenLinks;
+  PortVisibility?: Record<string, PortVisibility> | null;
+  WorktreeMissing?: boolean;
 };
 
 export type Project = {
   Path: string;
+  Mounts?: MountConfig[];
   Workspaces: Record<string, Workspace>;
 };
 
 export type ProjectsMap = Record<string, Project>;
+
+export type JobStatus = "running" | "succeeded" | "failed" | "canceled";
+
+// failure of `devcontainer up`
+export type UpErrorDetail = {
+  Outcome: string;
+  Message: string;
+  Description: string;
+  ExitCode: number;
+  // last lines of stderr. the failed feature or Dockerfile step is usually here
+  Stderr: string[] | null;
+};
+
+export type Job = {
+  Id: string;
+  Kind: string;
+  ProjectName: string;
+  WorkspaceName: string;
+  Status: JobStatus;
+  Progress: string;
+  Error: string;
+  ErrorDetail?: UpErrorDetail;
+};
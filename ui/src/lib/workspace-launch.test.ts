@@ -3,6 +3,7 @@ import type { WorkspaceOpenLinks } from "./types";
 import {
   buildWorkspaceOpenLinkUrl,
   getRenderableOpenLinks,
+  hasPendingOpenLinks,
   parseWorkspacePluginSelectionCookie,
   serializeWorkspacePluginSelectionCookie,
 } from "./workspace-launch";
@@ -30,6 +31,21 @@ describe("workspace-launch", () => {
     ]);
   });
 
+  it("hides links whose readiness probe has not succeeded yet", () => {
+    const links: WorkspaceOpenLinks = {
+      Editor: { Port: 8080, Path: "", Probe: { Type: "http" }, Readiness: "notReady" },
+      Docs: { Port: 3000, Path: "/docs", Readiness: "unknown" },
+      Api: { Port: 4000, Path: "", Probe: { Type: "tcp" }, Readiness: "ready" },
+    };
+
+    expect(getRenderableOpenLinks("http://localhost", "alpha", "ws-a1", links)).toEqual([
+      { name: "Api", url: "http://localhost/port/alpha/ws-a1/4000" },
+      { name: "Docs", url: "http://localhost/port/alpha/ws-a1/3000/docs" },
+    ]);
+    expect(hasPendingOpenLinks(links)).toBe(true);
+    expect(hasPendingOpenLinks({ Docs: links.Docs })).toBe(false);
+  });
+
   it("serializes and restores cookie-backed plugin selections per workspace", () => {
     const cookie = serializeWorkspacePluginSelectionCookie("alpha", "ws-a1", ["docs", "admin", "docs"]);
     const cookieHeader = `theme=dark; ${cookie}; other=value`;
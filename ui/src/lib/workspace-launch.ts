@@ -80,10 +80,14 @@ export function getRenderableOpenLinks(
   links: WorkspaceOpenLinks,
 ): RenderableOpenLink[] {
   return Object.entries(links)
-    .filter(([, link]) => link.Port > 0)
+    .filter(([, link]) => link.Port > 0 && link.Readiness !== "notReady")
     .sort(([leftName], [rightName]) => leftName.localeCompare(rightName))
     .map(([name, link]) => ({
       name,
       url: buildWorkspaceOpenLinkUrl(origin, projectName, workspaceName, link),
     }));
 }
+
+export function hasPendingOpenLinks(links: WorkspaceOpenLinks): boolean {
+  return Object.values(links).some((link) => link.Port > 0 && link.Readiness === "notReady");
+}